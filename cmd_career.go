@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var flagCareerN = 5
+
+var cmdCareer = &command{
+	name:            "career",
+	positionalUsage: "query",
+	shortHelp:       "summarize the career of an actor",
+	help: `
+The career command summarizes the career of the actor matching the query given
+(the search is automatically restricted to actors). It shows their number of
+credits, their first and last credits, the average rank of their movies and TV
+shows and how many of their roles were lead or supporting roles (from their
+billing). Then it draws a timeline of their credits per year, and lists their
+most common genres and their longest running TV roles.
+
+Credits in TV episodes are counted as credits in their TV show, so that
+hundreds of episodes of the same show count once in each year. A lead role is
+billed in the top 3. Only the characters played in more than one episode of a
+TV show are TV roles.
+
+The layout is controlled by the "career" template in your command.tpl file.
+The summary is computed by the "career" template function, which can also be
+used in other templates.
+
+The 'actors' and 'actresses' lists must be loaded for this command to be
+useful. The 'genres' and 'ratings' lists are used for genres and ranks.
+`,
+	flags: flag.NewFlagSet("career", flag.ExitOnError),
+	run:   cmd_career,
+	addFlags: func(c *command) {
+		c.flags.IntVar(&flagCareerN, "n", flagCareerN,
+			"The number of genres and TV roles to list.")
+	},
+}
+
+func cmd_career(c *command) bool {
+	c.assertLeastNArg(1)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	actor, ok := c.oneEntityOf(db, imdb.EntityActor)
+	if !ok {
+		return false
+	}
+
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{"N": flagCareerN}
+	c.tplExec(c.tpl("career"), tpl.Args{E: actor, A: attrs})
+	return true
+}
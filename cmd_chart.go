@@ -0,0 +1,226 @@
+package main
+
+import (
+	"flag"
+	"sort"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/imdb/search"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var (
+	flagChartVotes = 0
+	flagChartN     = 5
+)
+
+var cmdChart = &command{
+	name:            "chart",
+	other:           true,
+	positionalUsage: "query",
+	shortHelp:       "show how the episodes of a TV show were ranked",
+	help: `
+The chart command shows how the quality of a TV show evolved over time. The
+query given should match a single TV show (the search is automatically
+restricted to TV shows). Each season of the show is drawn as a sparkline of the
+IMDb user rank of its episodes, followed by a grid of the ranks themselves.
+The best and worst episodes of each season are marked with a '*' and a '!',
+respectively. Finally, the best and worst episodes of the entire show are
+listed.
+
+The layout is controlled by the "chart", "chart_season" and "chart_episode"
+templates in your command.tpl file.
+
+The 'ratings' list must be loaded for this command to be useful.
+`,
+	flags: flag.NewFlagSet("chart", flag.ExitOnError),
+	run:   cmd_chart,
+	addFlags: func(c *command) {
+		c.flags.IntVar(&flagChartVotes, "votes", flagChartVotes,
+			"The minimum number of votes an episode must have to be\n"+
+				"considered one of the best or worst episodes.")
+		c.flags.IntVar(&flagChartN, "n", flagChartN,
+			"The number of best and worst episodes to list for the show.")
+	},
+}
+
+// chartEpisode is a single episode in a TV show chart.
+type chartEpisode struct {
+	*imdb.Episode
+	Rank        imdb.UserRank
+	Best, Worst bool // best or worst in its season
+}
+
+// chartSeason is a single season of episodes in a TV show chart.
+type chartSeason struct {
+	Season   int
+	Episodes []*chartEpisode
+
+	// Ranks has the rank of each episode in the season in order, where
+	// unranked episodes have a rank of -1. Useful for sparklines.
+	Ranks []int
+
+	// Mean is the average rank of all ranked episodes in the season and
+	// Votes is the total number of votes for the season.
+	Mean  float64
+	Votes int
+
+	// Lo and Hi are the lowest and highest ranks of any episode in the
+	// entire TV show, so that sparklines can be drawn on the same scale for
+	// every season.
+	Lo, Hi int
+}
+
+func cmd_chart(c *command) bool {
+	c.assertLeastNArg(1)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	ent, ok := c.oneEntityOf(db, imdb.EntityTvshow)
+	if !ok {
+		return false
+	}
+	tv := ent.(*imdb.Tvshow)
+	seasons, err := chartSeasons(db, tv)
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+	if len(seasons) == 0 {
+		pef("Could not find any episodes for %s", tv)
+		return false
+	}
+
+	var ranked []*chartEpisode
+	for _, s := range seasons {
+		for _, ep := range s.Episodes {
+			if ep.Rank.Votes > 0 && ep.Rank.Votes >= flagChartVotes {
+				ranked = append(ranked, ep)
+			}
+		}
+	}
+	sort.Sort(byEpisodeRank(ranked))
+	n := flagChartN
+	if n > len(ranked) {
+		n = len(ranked)
+	}
+	best := ranked[:n]
+	worst := make([]*chartEpisode, n)
+	for i := 0; i < n; i++ {
+		worst[i] = ranked[len(ranked)-1-i]
+	}
+
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{"Seasons": seasons, "Best": best, "Worst": worst}
+	c.tplExec(c.tpl("chart"), tpl.Args{E: tv, A: attrs})
+	return true
+}
+
+// chartSeasons returns every season of the given TV show along with the ranks
+// of its episodes. Episodes without season or episode numbers are omitted.
+func chartSeasons(db *imdb.DB, tv *imdb.Tvshow) ([]*chartSeason, error) {
+	epsearch := search.New(db)
+	epsearch.Entity(imdb.EntityEpisode)
+	epsearch.Tvshow(search.New(db).Atom(tv.Id))
+	epsearch.Seasons(1, -1).Episodes(1, -1)
+	epsearch.Sort("season", "asc").Sort("episode", "asc")
+	epsearch.Limit(-1)
+
+	results, err := epsearch.Results()
+	if err != nil {
+		return nil, err
+	}
+	episodes, err := tv.Episodes(db)
+	if err != nil {
+		return nil, err
+	}
+	byId := make(map[imdb.Atom]*imdb.Episode, len(episodes))
+	for _, e := range episodes {
+		byId[e.Id] = e
+	}
+
+	var seasons []*chartSeason
+	var cur *chartSeason
+	for _, r := range results {
+		e, ok := byId[r.Id]
+		if !ok {
+			continue
+		}
+		ep := &chartEpisode{Episode: e, Rank: r.Rank}
+		if cur == nil || cur.Season != ep.Season {
+			cur = &chartSeason{Season: ep.Season}
+			seasons = append(seasons, cur)
+		}
+		cur.Episodes = append(cur.Episodes, ep)
+	}
+	lo, hi := 100, 0
+	for _, s := range seasons {
+		s.summarize()
+		for _, rank := range s.Ranks {
+			if rank < 0 {
+				continue
+			}
+			if rank < lo {
+				lo = rank
+			}
+			if rank > hi {
+				hi = rank
+			}
+		}
+	}
+	for _, s := range seasons {
+		s.Lo, s.Hi = lo, hi
+	}
+	return seasons, nil
+}
+
+// summarize computes the ranks, mean and total votes of a season and marks
+// its best and worst episodes.
+func (s *chartSeason) summarize() {
+	var best, worst *chartEpisode
+	total, count := 0, 0
+	for _, ep := range s.Episodes {
+		if ep.Rank.Unranked() {
+			s.Ranks = append(s.Ranks, -1)
+			continue
+		}
+		s.Ranks = append(s.Ranks, ep.Rank.Rank)
+		s.Votes += ep.Rank.Votes
+		total += ep.Rank.Rank
+		count++
+
+		if ep.Rank.Votes < flagChartVotes {
+			continue
+		}
+		if best == nil || episodeRankLess(ep, best) {
+			best = ep
+		}
+		if worst == nil || episodeRankLess(worst, ep) {
+			worst = ep
+		}
+	}
+	if count > 0 {
+		s.Mean = float64(total) / float64(count)
+	}
+	// Don't bother highlighting anything if every episode is the same.
+	if best != nil && best != worst {
+		best.Best, worst.Worst = true, true
+	}
+}
+
+// episodeRankLess returns true if ep1 is ranked better than ep2. Ties are
+// broken by the number of votes.
+func episodeRankLess(ep1, ep2 *chartEpisode) bool {
+	if ep1.Rank.Rank != ep2.Rank.Rank {
+		return ep1.Rank.Rank > ep2.Rank.Rank
+	}
+	return ep1.Rank.Votes > ep2.Rank.Votes
+}
+
+type byEpisodeRank []*chartEpisode
+
+func (eps byEpisodeRank) Len() int      { return len(eps) }
+func (eps byEpisodeRank) Swap(i, j int) { eps[i], eps[j] = eps[j], eps[i] }
+func (eps byEpisodeRank) Less(i, j int) bool {
+	return episodeRankLess(eps[i], eps[j])
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/imdb/graph"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var flagFranchiseDot = false
+
+var cmdFranchise = &command{
+	name:            "franchise",
+	positionalUsage: "query",
+	shortHelp:       "show the sequels, remakes and spin offs of media",
+	help: `
+The franchise command finds every movie, TV show and episode in the franchise
+of the media matching the query given. It follows the sequels ('follows' and
+'followed by'), remakes ('remake of' and 'remade as') and spin offs ('spin
+off' and 'spin off from') of the media, then the sequels, remakes and spin
+offs of those, and so on.
+
+The franchise is shown in two watch orders: the order of release and the
+order of the story (i.e., with prequels first). The order of the story is
+given by the sequel links, and can't be determined if they contradict each
+other. Finally, every link in the franchise is listed.
+
+When '-dot' is set, the franchise is written as a graph in the Graphviz DOT
+language instead. For example, to draw it as an image:
+
+    goim franchise -dot 'star wars {movie}' | dot -Tpng > star-wars.png
+
+The layout is controlled by the "franchise" template in your command.tpl file.
+
+The 'movie-links' list must be loaded for this command to be useful.
+`,
+	flags: flag.NewFlagSet("franchise", flag.ExitOnError),
+	run:   cmd_franchise,
+	addFlags: func(c *command) {
+		c.flags.BoolVar(&flagFranchiseDot, "dot", flagFranchiseDot,
+			"When set, the franchise is written in the Graphviz DOT\n"+
+				"language.")
+	},
+}
+
+func cmd_franchise(c *command) bool {
+	c.assertLeastNArg(1)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	media, ok := c.oneEntity(db)
+	if !ok {
+		return false
+	}
+	if media.Type() == imdb.EntityActor {
+		pef("%s is not a movie, TV show or episode.", media)
+		return false
+	}
+	f, err := graph.FindFranchise(db, media)
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+
+	if flagFranchiseDot {
+		if err := f.WriteDOT(os.Stdout); err != nil {
+			pef("%s", err)
+			return false
+		}
+		return true
+	}
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{
+		"Media":         f.Media,
+		"Chronological": f.Chronological,
+		"Links":         f.Links,
+	}
+	c.tplExec(c.tpl("franchise"), tpl.Args{E: media, A: attrs})
+	return true
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/imdb/graph"
+)
+
+var (
+	flagGraphFormat = "dot"
+	flagGraphActors = false
+)
+
+var cmdGraph = &command{
+	name:            "graph",
+	positionalUsage: "export query",
+	shortHelp:       "export the network of credits of search results",
+	help: `
+The graph command exports the network of credits of the results of a search
+query, for analysis with other tools. Currently, 'export' is its only
+subcommand. Every actor in the results is connected to every movie, TV show
+and episode they are credited in, and every movie, TV show and episode in the
+results is connected to every actor credited in it. For example, to export
+the network of the 100 best ranked movies with at least 10,000 votes:
+
+    goim graph export '{movie} {votes:10000-} {sort:rank desc} {limit:100}'
+
+The network is written in the format given by '-format': 'dot' (Graphviz),
+'graphml' or 'gexf' (Gephi). Actors and media have attributes for their kind,
+year, user_rank, votes and gender (of actors). Each edge is weighted by
+billing: an actor billed at position N is connected with a weight of 1/N, and
+unbilled actors are weighted as if billed at position 100. In DOT, the weight
+is the 'billing_weight' attribute, since Graphviz only allows integer weights.
+
+When '-actors' is set, the network is projected onto actors instead: two
+actors are connected when they are credited in the same media, and the weight
+of the connection is the sum of the products of their weights in every media
+they share.
+
+Use the flags to ignore credits in TV episodes, credits for archive footage or
+credits in media that few users have voted on. The media in the results are
+never ignored.
+
+The 'actors' and 'actresses' lists must be loaded for this command to be
+useful. The 'ratings' list must be loaded to use '-votes'.
+`,
+	flags: flag.NewFlagSet("graph", flag.ExitOnError),
+	run:   cmd_graph,
+	addFlags: func(c *command) {
+		addGraphFlags(c)
+		c.flags.StringVar(&flagGraphFormat, "format", flagGraphFormat,
+			"The format to write the network in: 'dot', 'graphml' or\n"+
+				"'gexf'.")
+		c.flags.BoolVar(&flagGraphActors, "actors", flagGraphActors,
+			"When set, the network is projected onto actors.")
+	},
+}
+
+func cmd_graph(c *command) bool {
+	c.assertLeastNArg(2)
+	if sub := c.flags.Arg(0); sub != "export" {
+		pef("Unknown graph command '%s'. The only command is 'export'.", sub)
+		return false
+	}
+
+	var write func(n *graph.Network) error
+	switch flagGraphFormat {
+	case "dot":
+		write = func(n *graph.Network) error { return n.WriteDOT(os.Stdout) }
+	case "graphml":
+		write = func(n *graph.Network) error {
+			return n.WriteGraphML(os.Stdout)
+		}
+	case "gexf":
+		write = func(n *graph.Network) error { return n.WriteGEXF(os.Stdout) }
+	default:
+		pef("Unknown format '%s'. Use 'dot', 'graphml' or 'gexf'.",
+			flagGraphFormat)
+		return false
+	}
+
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	query := strings.Join(c.flags.Args()[1:], " ")
+	results, ok := c.queryResults(db, query, false)
+	if !ok {
+		return false
+	}
+	var actors, media []imdb.Atom
+	for _, r := range results {
+		if r.Entity == imdb.EntityActor {
+			actors = append(actors, r.Id)
+		} else {
+			media = append(media, r.Id)
+		}
+	}
+
+	n, err := graph.CreditNetwork(db, actors, media, graphOptions())
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+	if flagGraphActors {
+		n = n.Actors()
+	}
+	if err := write(n); err != nil {
+		pef("%s", err)
+		return false
+	}
+	return true
+}
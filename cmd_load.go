@@ -10,6 +10,8 @@ import (
 
 	"github.com/BurntSushi/ty/fun"
 
+	"github.com/BurntSushi/csql"
+
 	"github.com/BurntSushi/goim/imdb"
 )
 
@@ -218,6 +220,18 @@ func cmd_load(c *command) bool {
 		return false
 	}
 
+	// Episode air dates are derived from both the movies and release dates
+	// lists, so they need updating if either one is loaded.
+	airDates := loaderIndex("movies", userLoadLists) > -1 ||
+		loaderIndex("release-dates", userLoadLists) > -1
+
+	// Names are only added by the movies and actors lists, so the trigram
+	// index of names (used for fuzzy searching with SQLite) only needs
+	// rebuilding when one of them is loaded.
+	trigrams := db.Driver == "sqlite3" &&
+		(loaderIndex("movies", userLoadLists) > -1 ||
+			loaderIndex("actors", userLoadLists) > -1)
+
 	// Before launching into loading---which can be done in parallel---we need
 	// to load movies and actors first since they insert data that most of the
 	// other lists depend on. Also, they cannot be loaded in parallel since
@@ -291,9 +305,58 @@ func cmd_load(c *command) bool {
 		pef("Could not create indices: %s", err)
 		return false
 	}
+	if airDates {
+		logf("Updating episode air dates...")
+		if err := updateAirDates(db); err != nil {
+			pef("Could not update episode air dates: %s", err)
+			return false
+		}
+	}
+	// Names added by the movies and actors lists already have search keys,
+	// but names in databases created before search keys existed don't.
+	// This is quick when every name has a key. Since trigrams are indexed
+	// from search keys, they must be reindexed if any keys were added.
+	logf("Updating search keys of names...")
+	if n, err := db.UpdateNameKeys(); err != nil {
+		pef("Could not update search keys of names: %s", err)
+		return false
+	} else if n > 0 && db.Driver == "sqlite3" {
+		trigrams = true
+	}
+	if trigrams {
+		logf("Indexing name trigrams for fuzzy searching...")
+		if err := db.IndexTrigrams(); err != nil {
+			pef("Could not index name trigrams: %s", err)
+			return false
+		}
+	}
 	return true
 }
 
+// updateAirDates sets the air date of every episode with release dates to its
+// earliest release date. Every such episode is updated, so that air dates
+// follow the release dates when they are reloaded. Air dates that were parsed
+// from episode identifiers in the movies list are only kept for episodes
+// without any release dates.
+//
+// This must be done after indices are created, since it scans the release
+// dates of every episode.
+func updateAirDates(db *imdb.DB) (err error) {
+	defer csql.Safe(&err)
+	csql.Exec(db, `
+		UPDATE episode
+		SET aired = (
+			SELECT MIN(released) FROM release_date
+			WHERE release_date.atom_id = episode.atom_id
+		)
+		WHERE EXISTS (
+			SELECT 1 FROM release_date
+			WHERE release_date.atom_id = episode.atom_id
+		)
+		`)
+	return
+}
+
 func downloadList(fetch fetcher, name string) error {
 	list, err := fetch.list(name)
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"log"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/csql"
 
@@ -52,6 +53,10 @@ func (mf mapFetcher) list(name string) (io.ReadCloser, error) {
 	return readCloser{strings.NewReader(mf[name])}, nil
 }
 
+func (mf mapFetcher) location(name string) string {
+	return name
+}
+
 func init() {
 	var err error
 	testDB, err = imdb.Open(testDriver, testDsn)
@@ -80,3 +85,32 @@ func TestLoadMovies(t *testing.T) {
 		t.Fatalf("Expected %d episodes but got %d", exp["episodes"], episodes)
 	}
 }
+
+func TestParseEpisodeDate(t *testing.T) {
+	date := func(y, m, d int) time.Time {
+		return time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		inBraces string
+		start    int
+		aired    time.Time
+	}{
+		{"(2001-01-07)", 0, date(2001, 1, 7)},
+		{"Guest Host (1999-12-31)", 11, date(1999, 12, 31)},
+		{"Pilot", 5, time.Time{}},
+		{"(#1.2)", 6, time.Time{}},
+		{"(2001-13-07)", 12, time.Time{}},
+		{"(2001-1-7)", 10, time.Time{}},
+		{"Finale 2001-01-07)", 18, time.Time{}},
+		{"(2001-01-07", 11, time.Time{}},
+		{")", 1, time.Time{}},
+	}
+	for _, test := range tests {
+		var aired time.Time
+		start := parseEpisodeDate([]byte(test.inBraces), &aired)
+		if start != test.start || !aired.Equal(test.aired) {
+			t.Errorf("Expected '%s' to have a date %s at %d, but got %s "+
+				"at %d.", test.inBraces, test.aired, test.start, aired, start)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/BurntSushi/goim/imdb/graph"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+// These flags are shared by every command that uses the graph of credits.
+var (
+	flagGraphNoEpisodes = false
+	flagGraphVotes      = 0
+	flagGraphNoArchive  = false
+)
+
+var flagPathMax = 6
+
+var cmdPath = &command{
+	name:            "path",
+	positionalUsage: "query query",
+	shortHelp:       "find the shortest path between two actors or media",
+	help: `
+The path command finds the shortest path between two actors, where each step
+of the path connects two actors that appeared in the same movie, TV show or
+episode. (i.e., the "six degrees of Kevin Bacon".) It can also find the
+shortest path between two movies, TV shows or episodes, where each step
+connects two of them that have an actor in common.
+
+Each query given should match a single entity, and both entities must be
+actors or both must be media. Queries with more than one word must be quoted.
+For example:
+
+    goim path 'kevin bacon' 'tom hanks'
+    goim path '{movie} the matrix' '{movie} casablanca'
+
+Use the flags to ignore credits in TV episodes, credits for archive footage or
+credits in media that few users have voted on. Ignoring them usually gives
+paths that are more interesting.
+
+The layout is controlled by the "path" template in your command.tpl file.
+
+The 'actors' and 'actresses' lists must be loaded for this command to be
+useful. The 'ratings' list must be loaded to use '-votes'.
+`,
+	flags: flag.NewFlagSet("path", flag.ExitOnError),
+	run:   cmd_path,
+	addFlags: func(c *command) {
+		addGraphFlags(c)
+		c.flags.IntVar(&flagPathMax, "max", flagPathMax,
+			"The maximum degrees of separation to search. When set to 0,\n"+
+				"paths of any length are searched for.")
+	},
+}
+
+func cmd_path(c *command) bool {
+	c.assertNArg(2)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	from, ok := c.queryEntity(db, c.flags.Arg(0))
+	if !ok {
+		return false
+	}
+	to, ok := c.queryEntity(db, c.flags.Arg(1))
+	if !ok {
+		return false
+	}
+
+	opts := graphOptions()
+	opts.MaxDegrees = flagPathMax
+	path, err := graph.ShortestPath(db, from, to, opts)
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+	if path == nil {
+		pef("Could not find a path between %s and %s.", from, to)
+		return false
+	}
+
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{"To": path.To, "Hops": path.Hops}
+	c.tplExec(c.tpl("path"), tpl.Args{E: path.From, A: attrs})
+	return true
+}
+
+// addGraphFlags adds the flags that restrict the credits followed by commands
+// that use the graph of credits.
+func addGraphFlags(c *command) {
+	c.flags.BoolVar(&flagGraphNoEpisodes, "no-episodes", flagGraphNoEpisodes,
+		"When set, credits in TV episodes are ignored.")
+	c.flags.IntVar(&flagGraphVotes, "votes", flagGraphVotes,
+		"The minimum number of votes that media must have for its\n"+
+			"credits to be used.")
+	c.flags.BoolVar(&flagGraphNoArchive, "no-archive", flagGraphNoArchive,
+		"When set, credits for archive footage are ignored.")
+}
+
+// graphOptions returns the graph options corresponding to the flags added by
+// addGraphFlags.
+func graphOptions() graph.Options {
+	return graph.Options{
+		NoEpisodes: flagGraphNoEpisodes,
+		MinVotes:   flagGraphVotes,
+		NoArchive:  flagGraphNoArchive,
+	}
+}
@@ -55,7 +55,8 @@ guess anything---the results of your search are taken for gospel.
 
 This command can also be smart by looking for key pieces of information that
 are frequently in similar formats (like years or season/episode numbers). Note
-that this "smart" mode assumes that fuzzy searching is available.
+that this "smart" mode assumes that fuzzy searching is available. (It is
+available with SQLite, or with PostgreSQL and the 'pg_trgm' extension.)
 
 If the first argument is a file name (i.e., the query is omitted), then Goim
 will try to be smart and guess what the file corresponds to based on any name
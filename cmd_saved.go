@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var cmdSaved = &command{
+	name:            "saved",
+	positionalUsage: "(list | run name [ argument ... ])",
+	shortHelp:       "list or run saved searches",
+	help: `
+The saved command lists or runs the saved searches in the configuration file.
+
+Saved searches are defined in the 'saved' table of the configuration. Each
+saved search has a name and a search query:
+
+    [saved]
+    good = "{votes:10000-} {novideo} {notv} {sort:rank desc}"
+    decade = "{movie} {@good} {years:$1-$2}"
+
+A saved search can be used inside any search query with the directive
+'{@name}', which is replaced by its query before the search query is parsed.
+Saved searches may be used in other saved searches, as long as they don't
+reference each other in a cycle.
+
+Saved searches may have arguments, which are given after a ':' and separated
+by whitespace. In the query of a saved search, '$1' is replaced by the first
+argument, '$2' by the second and so on. '$*' is replaced by all of the
+arguments and '$$' is a literal '$'. For example, the best movies of the 1990s
+with Tom Hanks:
+
+    goim search {@decade:1990 1999} {cast:tom hanks}
+
+'goim saved list' shows every saved search, and 'goim saved run name' runs
+the saved search named with any arguments given. For example, the following
+is the same as 'goim search {@decade:1990 1999}':
+
+    goim saved run decade 1990 1999
+`,
+	flags: flag.NewFlagSet("saved", flag.ExitOnError),
+	run:   cmd_saved,
+}
+
+func cmd_saved(c *command) bool {
+	c.assertLeastNArg(1)
+	switch c.flags.Arg(0) {
+	case "list":
+		c.assertNArg(1)
+		return savedList(c)
+	case "run":
+		c.assertLeastNArg(2)
+		return savedRun(c)
+	default:
+		pef("Unknown command '%s'.", c.flags.Arg(0))
+		return false
+	}
+}
+
+func savedList(c *command) bool {
+	saved := c.savedSearches()
+	if len(saved) == 0 {
+		pef("No saved searches found.")
+		return false
+	}
+
+	var names []string
+	for name := range saved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tabw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	for _, name := range names {
+		fmt.Fprintf(tabw, "@%s\t%s\n", name, saved[name])
+	}
+	tabw.Flush()
+	return true
+}
+
+func savedRun(c *command) bool {
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	args := c.flags.Args()[1:]
+	query := sf("{@%s}", args[0])
+	if len(args) > 1 {
+		query = sf("{@%s:%s}", args[0], strings.Join(args[1:], " "))
+	}
+	results, ok := c.queryResults(db, query, false)
+	if !ok {
+		return false
+	}
+	template := c.tpl("search_result")
+	for i, result := range results {
+		attrs := tpl.Attrs{"Index": i + 1}
+		c.tplExec(template, tpl.Args{E: result, A: attrs})
+	}
+	return true
+}
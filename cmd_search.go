@@ -2,15 +2,22 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"os"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/kr/text"
 
+	"github.com/BurntSushi/goim/imdb"
 	"github.com/BurntSushi/goim/imdb/search"
 	"github.com/BurntSushi/goim/tpl"
 )
 
-var flagSearchIds = false
+var (
+	flagSearchIds    = false
+	flagSearchFacets = false
+)
 
 var cmdSearch = &command{
 	name:            "search",
@@ -23,6 +30,11 @@ var cmdSearch = &command{
 		c.flags.BoolVar(&flagSearchIds, "ids", flagSearchIds,
 			"When set, only the atom identifiers of each search result "+
 				"will be printed.")
+		c.flags.BoolVar(&flagSearchFacets, "facets", flagSearchFacets,
+			"When set, the total number of results is printed along with\n"+
+				"the number of results by entity, genre, decade, MPAA rating\n"+
+				"and rank, instead of the results themselves. The limit of\n"+
+				"the search is ignored.")
 	},
 }
 
@@ -51,23 +63,49 @@ a limit on the number of results returned.
 
 The search query is composed of whitespace delimited tokens. Each token that 
 starts and ends with a '{' and '}' is a directive. All other tokens are used as 
-text to search the names of entities.
-
-If you're using PostgreSQL with the 'pg_trgm' extension enabled, then text 
-searching is fuzzy. Otherwise, text may contain the wildcard '%%' which matches 
-any sequence of characters or the wildcard '_' which matches any single 
-character. Whenever a wildcard character is used, fuzzy search is disabled (and 
-the search will be case insensitive).
+text to search the names of entities. Text surrounded by double quotes is a 
+single token, and any character preceded by a backslash is treated as text. 
+For example, '"the  office"' keeps both spaces and '\{' searches for a '{'.
+
+If you're using PostgreSQL with the 'pg_trgm' extension enabled, or SQLite 
+with names loaded by 'goim load', then text searching is fuzzy. Otherwise, text 
+may contain the wildcard '%%' which matches any sequence of characters or the 
+wildcard '_' which matches any single character. Whenever a wildcard character 
+is used, fuzzy search is disabled.
+
+Text searches ignore case, accents, punctuation and leading articles. For 
+example, 'amelie' finds 'Amélie', and 'simpsons', 'The Simpsons' and 
+'Simpsons, The' all find the same TV show. Names are always shown as they are 
+in IMDb's data. When a search has no results, a relaxed version of it that 
+does (e.g., with a lower similarity threshold or without filters on years) is 
+suggested.
 
 Directives have the form '{NAME[:ARGUMENT]}', where NAME is the name of the 
 directive and ARGUMENT is an argument for the directive. Each directive either 
 requires no argument or requires a single argument.
 
+Tokens are combined conjunctively: every directive must be satisfied. (Except 
+for entity types, genres and similar directives repeated more than once, which 
+are combined disjunctively.) Other combinations can be expressed with '|', 
+which separates alternatives, and with parentheses, which group tokens. A 
+directive can be negated by prefixing its name with a '-' or with the 'not' 
+directive. For example, comedies that aren't animated and are either rated R 
+or have a rank of at least 80:
+
+  {genre:comedy} {-genre:animation} ({mpaa:R} | {rank:80-})
+
+Parentheses that only contain text are treated as part of the text, so 
+searching for '(500) days of summer' works as expected.
+
+Searches saved in the configuration file can be used in a query with the 
+directive '{@NAME}' or '{@NAME:ARGUMENTS}', which is replaced by the query of 
+the saved search. See 'goim help saved' for details.
+
 Examples
 --------
 The following are some example query strings. They can be used in 'goim search'
-as is. Note that examples without wildcards assume that a PostgreSQL database 
-is used with the 'pg_trgm' extension enabled. Some also assume that your 
+as is. Note that examples without wildcards assume that fuzzy searching is 
+available. Some also assume that your 
 database has certain data (for example, the 'actors' list must be loaded to use 
 the 'cast' and 'credits' directives).
 
@@ -125,6 +163,19 @@ top 10 best ranked Simpsons episodes with more than 500 votes:
 
   {show:simpsons} {sort:rank desc} {limit:10} {votes:500-}
 
+Sub-searches normally pick a single entity. Adding a '*' to the name of a
+sub-search directive uses every entity it finds instead, which is useful when
+there's more than one right answer. For example, to find the best episodes of
+any Star Trek series, or movies starring any actor in a 1980s horror movie:
+
+  {show*:star trek} {sort:rank desc} {votes:500-}
+  {cast*:{credits*:{genre:horror} {years:1980-1989} {movie}}} {movie}
+
+Multiple cast (or credits) sub-searches can be given to find movies with all
+of the actors, or with any of them when '{anycredits}' is added:
+
+  {cast:keanu reeves} {cast:carrie-anne moss}
+
 All search directives
 ---------------------
 %s
@@ -136,20 +187,71 @@ func cmd_search(c *command) bool {
 	db := openDb(c.dbinfo())
 	defer closeDb(db)
 
-	template := c.tpl("search_result")
-	results, ok := c.results(db, false)
-	if !ok {
+	if flagSearchFacets {
+		return searchFacets(c, db)
+	}
+
+	searcher := c.searcher(db)
+	if err := searcher.Query(strings.Join(c.flags.Args(), " ")); err != nil {
+		pef("%s", err)
 		return false
 	}
-	if flagSearchIds {
-		for _, result := range results {
+
+	// Results are printed as they're read, since searches with a large limit
+	// can return far too many results to keep in memory.
+	template := c.tpl("search_result")
+	count := 0
+	err := searcher.Each(func(result search.Result) error {
+		count++
+		if flagSearchIds {
 			pf("%d\n", result.Id)
-		}
-	} else {
-		for i, result := range results {
-			attrs := tpl.Attrs{"Index": i + 1}
+		} else {
+			attrs := tpl.Attrs{"Index": count}
 			c.tplExec(template, tpl.Args{E: result, A: attrs})
 		}
+		return nil
+	})
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+	if count == 0 {
+		noResults(searcher)
+		return false
+	}
+	return true
+}
+
+// searchFacets prints the total number of results of the search on the
+// command line along with their facets.
+func searchFacets(c *command, db *imdb.DB) bool {
+	searcher := c.searcher(db)
+	if err := searcher.Query(strings.Join(c.flags.Args(), " ")); err != nil {
+		pef("%s", err)
+		return false
+	}
+	count, err := searcher.Count()
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+	facets, err := searcher.Facets()
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+
+	tabw := tabwriter.NewWriter(os.Stdout, 0, 0, 4, ' ', 0)
+	fmt.Fprintf(tabw, "total\t%d\n", count)
+	for _, field := range search.FacetFields {
+		if len(facets[field]) == 0 {
+			continue
+		}
+		fmt.Fprintf(tabw, "\n%s\n", field)
+		for _, f := range facets[field] {
+			fmt.Fprintf(tabw, "    %s\t%d\n", f.Value, f.Count)
+		}
 	}
+	tabw.Flush()
 	return true
 }
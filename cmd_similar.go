@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var flagSimilarAmong = ""
+
+var cmdSimilar = &command{
+	name:            "similar",
+	positionalUsage: "query",
+	shortHelp:       "find media similar to a movie, TV show or episode",
+	help: `
+The similar command lists the media that are most similar to the movie, TV
+show or episode matching the query given ("more like this"). Media are scored
+by the genres, languages and top billed cast that they share with it, whether
+they are linked to it (e.g., as a sequel or a remake), how close their year is
+to its year and their user rank. The numbers of shared genres, languages and
+top billed actors are shown with each result.
+
+By default, only media of the same type are listed (e.g., movies similar to a
+movie). The '-among' flag can be set to a search query to choose which media
+are listed instead. All directives of the query apply, except for sorting. For
+example, the 10 best matches among movies with at least 1,000 votes:
+
+    goim similar -among '{movie} {votes:1000-} {limit:10}' 'the matrix'
+
+The layout is controlled by the "similar" template in your command.tpl file.
+
+The 'genres', 'language', 'movie-links' and 'ratings' lists, and the 'actors'
+and 'actresses' lists, should be loaded for this command to be useful.
+`,
+	flags: flag.NewFlagSet("similar", flag.ExitOnError),
+	run:   cmd_similar,
+	addFlags: func(c *command) {
+		c.flags.StringVar(&flagSimilarAmong, "among", flagSimilarAmong,
+			"When set to a search query, only media matching it are listed.")
+	},
+}
+
+func cmd_similar(c *command) bool {
+	c.assertLeastNArg(1)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	media, ok := c.oneEntity(db)
+	if !ok {
+		return false
+	}
+	if media.Type() == imdb.EntityActor {
+		pef("%s is not a movie, TV show or episode.", media)
+		return false
+	}
+
+	searcher := c.searcher(db)
+	if len(strings.TrimSpace(flagSimilarAmong)) > 0 {
+		if err := searcher.Query(flagSimilarAmong); err != nil {
+			pef("%s", err)
+			return false
+		}
+	} else {
+		searcher.Entity(media.Type())
+	}
+	results, err := searcher.Similar(media)
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{"Results": results}
+	c.tplExec(c.tpl("similar"), tpl.Args{E: media, A: attrs})
+	return true
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/imdb/graph"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var flagCostarsN = 20
+
+var cmdTogether = &command{
+	name:            "together",
+	positionalUsage: "query query",
+	shortHelp:       "list the credits shared by two actors or media",
+	help: `
+The together command lists every movie, TV show and episode that two actors
+were both credited in, along with their characters and billing positions. If
+both queries match media instead, then every actor credited in both of them is
+listed.
+
+Each query given should match a single entity. Queries with more than one word
+must be quoted. For example:
+
+    goim together 'keanu reeves' 'carrie-anne moss'
+
+The layout is controlled by the "together" template in your command.tpl file.
+
+The 'actors' and 'actresses' lists must be loaded for this command to be
+useful.
+`,
+	flags:    flag.NewFlagSet("together", flag.ExitOnError),
+	run:      cmd_together,
+	addFlags: addGraphFlags,
+}
+
+var cmdCostars = &command{
+	name:            "costars",
+	positionalUsage: "query",
+	shortHelp:       "rank the frequent co-stars of an actor",
+	help: `
+The costars command ranks the actors that share the most credits with the
+actor matching the query given. For each co-star, the number of shared credits
+is shown along with the highest and average billing positions of the co-star
+in them. (Co-stars with the same number of shared credits are ranked by their
+highest billing position.)
+
+The layout is controlled by the "costars" template in your command.tpl file.
+
+The 'actors' and 'actresses' lists must be loaded for this command to be
+useful.
+`,
+	flags: flag.NewFlagSet("costars", flag.ExitOnError),
+	run:   cmd_costars,
+	addFlags: func(c *command) {
+		addGraphFlags(c)
+		c.flags.IntVar(&flagCostarsN, "n", flagCostarsN,
+			"The number of co-stars to list. When negative, every co-star\n"+
+				"is listed.")
+	},
+}
+
+func cmd_together(c *command) bool {
+	c.assertNArg(2)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	a, ok := c.queryEntity(db, c.flags.Arg(0))
+	if !ok {
+		return false
+	}
+	b, ok := c.queryEntity(db, c.flags.Arg(1))
+	if !ok {
+		return false
+	}
+	hops, err := graph.Together(db, a, b, graphOptions())
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{"To": b, "Hops": hops}
+	c.tplExec(c.tpl("together"), tpl.Args{E: a, A: attrs})
+	return true
+}
+
+func cmd_costars(c *command) bool {
+	c.assertLeastNArg(1)
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	ent, ok := c.oneEntity(db)
+	if !ok {
+		return false
+	}
+	actor, ok := ent.(*imdb.Actor)
+	if !ok {
+		pef("%s is not an actor.", ent)
+		return false
+	}
+	costars, err := graph.Costars(db, actor, graphOptions(), flagCostarsN)
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+
+	tpl.SetDB(db)
+	attrs := tpl.Attrs{"Costars": costars}
+	c.tplExec(c.tpl("costars"), tpl.Args{E: actor, A: attrs})
+	return true
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"strings"
+
+	"github.com/BurntSushi/ty/fun"
+
+	"github.com/BurntSushi/goim/imdb"
+	"github.com/BurntSushi/goim/tpl"
+)
+
+var (
+	flagTopEntity = "movie"
+	flagTopGenre  = ""
+	flagTopDecade = 0
+	flagTopTvshow = ""
+	flagTopVotes  = -1
+	flagTopLimit  = 250
+)
+
+// topVotes is the default minimum number of votes for each type of entity
+// that can be charted.
+var topVotes = map[imdb.EntityKind]int{
+	imdb.EntityMovie:   25000,
+	imdb.EntityTvshow:  5000,
+	imdb.EntityEpisode: 500,
+}
+
+var cmdTop = &command{
+	name:            "top",
+	positionalUsage: "[ query ]",
+	shortHelp:       "show charts of the best ranked media",
+	help: `
+The top command shows a chart of the best ranked media, in the spirit of IMDb's
+Top 250. Results are sorted by a Bayesian weighted rank, which pulls the rank
+of media with few votes toward the mean rank of all media. This produces a much
+more sensible chart than sorting by rank alone.
+
+Charts can be restricted to an entity type, a genre, a decade or the episodes
+of a single TV show with the flags below. Any search query given is applied on
+top of the chart, so all of the directives in 'goim help search' work here
+too. For example, the best westerns of the 1960s that weren't made for TV or
+video:
+
+    goim top -genre western -decade 1960 {notv} {novideo}
+
+The best episodes of The Simpsons:
+
+    goim top -tv 'the simpsons'
+
+Far fewer users vote on TV shows and episodes than on movies, so the minimum
+number of votes depends on what is charted, unless it is set with '-votes':
+25,000 for movies, 5,000 for TV shows and 500 for episodes (including the
+episodes charted with '-tv').
+
+Note that any sorting directives in the query are used to break ties in the
+weighted rank.
+`,
+	flags: flag.NewFlagSet("top", flag.ExitOnError),
+	run:   cmd_top,
+	addFlags: func(c *command) {
+		c.flags.StringVar(&flagTopEntity, "entity", flagTopEntity,
+			"The type of entity to chart: movie, tvshow or episode.\n"+
+				"This is ignored when '-tv' is set.")
+		c.flags.StringVar(&flagTopGenre, "genre", flagTopGenre,
+			"When set, only media with the given genre are charted.")
+		c.flags.IntVar(&flagTopDecade, "decade", flagTopDecade,
+			"When set, only media released in the decade starting with\n"+
+				"the year given are charted. e.g., '-decade 1990'.")
+		c.flags.StringVar(&flagTopTvshow, "tv", flagTopTvshow,
+			"When set to a search query, the episodes of the matching TV\n"+
+				"show are charted.")
+		c.flags.IntVar(&flagTopVotes, "votes", flagTopVotes,
+			"The minimum number of votes required to appear on the chart.\n"+
+				"This is also the weight given to the mean rank. When\n"+
+				"negative, it depends on the type of entity charted.")
+		c.flags.IntVar(&flagTopLimit, "limit", flagTopLimit,
+			"The number of entries in the chart.")
+	},
+}
+
+func cmd_top(c *command) bool {
+	db := openDb(c.dbinfo())
+	defer closeDb(db)
+
+	searcher := c.searcher(db)
+	searcher.Sort("weighted", "desc").Limit(flagTopLimit)
+	ent := imdb.EntityEpisode
+	if len(flagTopTvshow) > 0 {
+		tvsearch := c.searcher(db)
+		if err := tvsearch.Query(flagTopTvshow); err != nil {
+			pef("%s", err)
+			return false
+		}
+		searcher.Tvshow(tvsearch)
+	} else {
+		var ok bool
+		ent, ok = imdb.Entities[strings.ToLower(flagTopEntity)]
+		if !ok || ent == imdb.EntityActor {
+			pef("Cannot chart entity type '%s'.", flagTopEntity)
+			return false
+		}
+	}
+	searcher.Entity(ent)
+	votes := flagTopVotes
+	if votes < 0 {
+		votes = topVotes[ent]
+	}
+	searcher.Votes(votes, -1).WeightVotes(votes)
+	if len(flagTopGenre) > 0 {
+		genre := strings.ToLower(flagTopGenre)
+		if !fun.In(genre, imdb.EnumGenres) {
+			pef("Unknown genre '%s'. Available genres: %s",
+				flagTopGenre, strings.Join(imdb.EnumGenres, ", "))
+			return false
+		}
+		searcher.Genre(genre)
+	}
+	if flagTopDecade > 0 {
+		start := flagTopDecade - flagTopDecade%10
+		searcher.Years(start, start+9)
+	}
+	if err := searcher.Query(strings.Join(c.flags.Args(), " ")); err != nil {
+		pef("%s", err)
+		return false
+	}
+
+	results, err := searcher.Results()
+	if err != nil {
+		pef("%s", err)
+		return false
+	}
+	if len(results) == 0 {
+		noResults(searcher)
+		return false
+	}
+	template := c.tpl("top_result")
+	for i, result := range results {
+		attrs := tpl.Attrs{"Index": i + 1}
+		c.tplExec(template, tpl.Args{E: result, A: attrs})
+	}
+	return true
+}
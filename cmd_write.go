@@ -17,6 +17,7 @@ var flagConfigOverwrite = false
 type config struct {
 	Driver     string
 	DataSource string `toml:"data_source"`
+	Saved      map[string]string
 }
 
 var defaultConfig = `
@@ -41,6 +42,17 @@ driver = "sqlite3"
 # N.B. The 'sslmode=disable' appears to be required for a default PostgreSQL
 # installation. (At least on Archlinux, anyway.)
 data_source = "goim.sqlite"
+
+# Saved searches are search queries that can be used in other search queries
+# with the directive '{@name}', or run with 'goim saved run name'. Saved
+# searches may take arguments, which are substituted for '$1', '$2', etc.
+# For example, '{@decade:1990 1999}' finds the best movies of the 1990s:
+#
+# [saved]
+# good = "{votes:10000-} {novideo} {notv} {sort:rank desc}"
+# decade = "{movie} {@good} {years:$1-$2}"
+#
+# (See 'goim help saved' for more details.)
 `
 
 var xdgPaths = xdg.Paths{XDGSuffix: "goim"}
@@ -53,6 +53,8 @@ type command struct {
 	addFlags        func(*command)
 	run             func(*command) bool
 	tpls            *template.Template
+	conf            *config
+	confErr         error
 	other           bool
 }
 
@@ -114,7 +116,10 @@ func (c *command) dbinfo() (driver, dsn string) {
 				driver = "sqlite3"
 				dsn = flagDb
 			} else if strings.HasSuffix(flagDb, "toml") {
-				conf, err := c.config(flagDb)
+				conf, err := c.config()
+				if err == nil {
+					err = conf.validate()
+				}
 				if err != nil {
 					fatalf("Error loading '%s' as config file: %s", flagDb, err)
 				}
@@ -127,7 +132,10 @@ func (c *command) dbinfo() (driver, dsn string) {
 			driver, dsn = dbInfo[0], dbInfo[1]
 		}
 	} else {
-		conf, err := c.config("")
+		conf, err := c.config()
+		if err == nil {
+			err = conf.validate()
+		}
 		if err != nil {
 			fatalf("If '-db' is not specified, then a configuration file\n"+
 				"must exist in $XDG_CONFIG_HOME/goim/config.toml\n\n"+
@@ -138,26 +146,128 @@ func (c *command) dbinfo() (driver, dsn string) {
 	return
 }
 
-// config loads the configuration from the file path given. If fpath has length
-// 0, then it will try to load the config from $XDG_CONFIG_HOME.
-func (c *command) config(fpath string) (conf config, err error) {
-	if len(fpath) == 0 {
-		fpath, err = xdgPaths.ConfigFile("config.toml")
+// config returns the configuration, which is loaded from the 'toml' file given
+// with '-db' or from $XDG_CONFIG_HOME. It is only loaded once per command.
+func (c *command) config() (config, error) {
+	if c.conf == nil {
+		c.conf = new(config)
+		c.confErr = c.loadConfig(c.conf)
+	}
+	return *c.conf, c.confErr
+}
+
+// loadConfig decodes the configuration file into conf. The configuration
+// isn't required when the database is given with '-db', so it isn't an error
+// if there is no configuration file in $XDG_CONFIG_HOME. If that file can't
+// be read, then the error is reported and the configuration is empty.
+func (c *command) loadConfig(conf *config) error {
+	if strings.HasSuffix(flagDb, "toml") {
+		_, err := toml.DecodeFile(flagDb, conf)
+		return err
 	}
-	_, err = toml.DecodeFile(fpath, &conf)
+	fpath, err := xdgPaths.ConfigFile("config.toml")
+	if err != nil {
+		if len(flagDb) > 0 {
+			return nil
+		}
+		return err
+	}
+	if _, err := toml.DecodeFile(fpath, conf); err != nil {
+		if len(flagDb) > 0 {
+			pef("Could not read config file '%s': %s", fpath, err)
+			*conf = config{}
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// validate returns an error if the configuration doesn't specify a database.
+func (conf config) validate() error {
 	if len(conf.Driver) == 0 || len(conf.DataSource) == 0 {
-		err = ef("Database driver '%s' or data source '%s' cannot be empty.",
+		return ef("Database driver '%s' or data source '%s' cannot be empty.",
 			conf.Driver, conf.DataSource)
 	}
-	return
+	return nil
+}
+
+// savedSearches returns the saved searches in the configuration. If there is
+// no configuration, then there are no saved searches. Unknown saved searches
+// are reported by the search query.
+func (c *command) savedSearches() search.Macros {
+	conf, err := c.config()
+	if err != nil {
+		pef("%s", err)
+		return nil
+	}
+	return search.Macros(conf.Saved)
+}
+
+// searcher returns a new searcher that can use saved searches in its queries.
+func (c *command) searcher(db *imdb.DB) *search.Searcher {
+	return search.New(db).Macros(c.savedSearches()).Chooser(c.chooser)
+}
+
+// noResults reports that a search has no results. If a relaxed version of the
+// search has results, then it is suggested along with its first few results.
+func noResults(searcher *search.Searcher) {
+	pef("No results found.")
+	sg, err := searcher.Suggest()
+	if err != nil {
+		pef("Could not find a suggestion: %s", err)
+		return
+	}
+	if sg == nil {
+		return
+	}
+	pef("Did you mean '%s'? (%s)",
+		sg.Query, strings.Join(sg.Relaxations, ", "))
+	for i, r := range sg.Results {
+		if i == 3 {
+			pef("  ...")
+			break
+		}
+		pef("  %s", r)
+	}
 }
 
 func (c *command) oneEntity(db *imdb.DB) (imdb.Entity, bool) {
-	r, ok := c.oneResult(db)
+	return c.queryEntity(db, strings.Join(c.flags.Args(), " "))
+}
+
+// queryEntity returns the single entity picked from the results of the query
+// given.
+func (c *command) queryEntity(db *imdb.DB, query string) (imdb.Entity, bool) {
+	return c.searchEntity(db, c.searcher(db), query)
+}
+
+// oneEntityOf is like oneEntity, except that the search is restricted to
+// entities of the kind given.
+func (c *command) oneEntityOf(
+	db *imdb.DB,
+	kind imdb.EntityKind,
+) (imdb.Entity, bool) {
+	searcher := c.searcher(db).Entity(kind)
+	return c.searchEntity(db, searcher, strings.Join(c.flags.Args(), " "))
+}
+
+// searchEntity returns the single entity picked from the results of the query
+// given, which is added to the searcher given.
+func (c *command) searchEntity(
+	db *imdb.DB,
+	searcher *search.Searcher,
+	query string,
+) (imdb.Entity, bool) {
+	if err := searcher.Query(query); err != nil {
+		pef("%s", err)
+		return nil, false
+	}
+	rs, ok := c.searchResults(searcher, true)
 	if !ok {
 		return nil, false
 	}
-	ent, err := r.GetEntity(db)
+	ent, err := rs[0].GetEntity(db)
 	if err != nil {
 		pef("%s\n", err)
 		return nil, false
@@ -165,29 +275,37 @@ func (c *command) oneEntity(db *imdb.DB) (imdb.Entity, bool) {
 	return ent, true
 }
 
-func (c *command) oneResult(db *imdb.DB) (*search.Result, bool) {
-	rs, ok := c.results(db, true)
-	if !ok || len(rs) == 0 {
-		return nil, false
-	}
-	return &rs[0], true
+func (c *command) results(db *imdb.DB, one bool) ([]search.Result, bool) {
+	return c.queryResults(db, strings.Join(c.flags.Args(), " "), one)
 }
 
-func (c *command) results(db *imdb.DB, one bool) ([]search.Result, bool) {
-	searcher, err := search.Query(db, strings.Join(c.flags.Args(), " "))
-	if err != nil {
+func (c *command) queryResults(
+	db *imdb.DB,
+	query string,
+	one bool,
+) ([]search.Result, bool) {
+	searcher := c.searcher(db)
+	if err := searcher.Query(query); err != nil {
 		pef("%s", err)
 		return nil, false
 	}
-	searcher.Chooser(c.chooser)
+	return c.searchResults(searcher, one)
+}
 
+// searchResults returns the results of the searcher given. If one is true,
+// then a single result is picked from them.
+func (c *command) searchResults(
+	searcher *search.Searcher,
+	one bool,
+) ([]search.Result, bool) {
+	searcher.Chooser(c.chooser)
 	results, err := searcher.Results()
 	if err != nil {
 		pef("%s", err)
 		return nil, false
 	}
 	if len(results) == 0 {
-		pef("No results found.")
+		noResults(searcher)
 		return nil, false
 	}
 	if one {
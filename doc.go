@@ -11,16 +11,26 @@ Use 'goim help {command}' for more details on {command}.
 
 A list of the main commands:
 
-    load      creates/updates database with IMDb data
-    rename    renames files to match search results
-    search    search IMDb for movies, TV shows, episodes and actors
-    size      lists size of tables and total size of database
-    write     write default configuration or templates
+    career       summarize the career of an actor
+    costars      rank the frequent co-stars of an actor
+    franchise    show the sequels, remakes and spin offs of media
+    graph        export the network of credits of search results
+    load         creates/updates database with IMDb data
+    path         find the shortest path between two actors or media
+    rename       renames files to match search results
+    saved        list or run saved searches
+    search       search IMDb for movies, TV shows, episodes and actors
+    similar      find media similar to a movie, TV show or episode
+    size         lists size of tables and total size of database
+    top          show charts of the best ranked media
+    together     list the credits shared by two actors or media
+    write        write default configuration or templates
 
 A list of other commands:
 
     aka-titles            show AKA titles for media
     alternate-versions    show alternate versions for media
+    chart                 show how the episodes of a TV show were ranked
     color-info            show color info for media
     credits               show actor/media credits
     full                  show exhaustive information about an entity
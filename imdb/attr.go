@@ -630,8 +630,62 @@ func (as *Quotes) ForEntity(db csql.Queryer, e Entity) error {
 // unrated.
 // *UserRank satisfies the Attributer interface.
 type UserRank struct {
-	Votes int
-	Rank  int
+	Votes        int
+	Rank         int
+	Distribution VoteDistribution
+}
+
+// VoteDistribution represents how the votes for a particular entity are
+// spread across the ten possible scores. It is stored exactly as it appears
+// in IMDb's ratings list: a string of ten characters, where the Nth character
+// corresponds to the share of votes with a score of N. A '.' means no votes,
+// a '*' means all votes and a digit 'd' means somewhere between 10*d and
+// 10*d+9 percent of the votes.
+//
+// The distribution may be empty if the ratings list was loaded by an older
+// version of Goim.
+type VoteDistribution string
+
+// Valid returns true if and only if the distribution has a share for each of
+// the ten scores.
+func (d VoteDistribution) Valid() bool {
+	if len(d) != 10 {
+		return false
+	}
+	for i := 0; i < len(d); i++ {
+		switch c := d[i]; {
+		case c == '.' || c == '*':
+		case c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Share returns the approximate percentage (from 0 to 100) of votes with the
+// score given, which must be in the range [1, 10]. Since IMDb only reports
+// shares in buckets of ten percent, the midpoint of the bucket is returned.
+// If the distribution isn't valid, then Share always returns 0.
+func (d VoteDistribution) Share(score int) int {
+	if !d.Valid() || score < 1 || score > 10 {
+		return 0
+	}
+	switch c := d[score-1]; c {
+	case '.':
+		return 0
+	case '*':
+		return 100
+	default:
+		return 10*int(c-'0') + 5
+	}
+}
+
+// Polarization returns the approximate percentage of votes that are at
+// either extreme of the scale (i.e., a score of 1 or 10). Higher values
+// indicate divisive or cult media.
+func (d VoteDistribution) Polarization() int {
+	return d.Share(1) + d.Share(10)
 }
 
 // Unranked returns true if and only if this rank has no votes.
@@ -785,7 +839,7 @@ func (r *Credits) ForEntity(db csql.Queryer, e Entity) error {
 	typedCredits := make([]Credit, len(credits))
 	for i, c := range credits {
 		if isActor {
-			med, err := fromAtomGuess(db, c.MediaId)
+			med, err := FromAtomGuess(db, c.MediaId)
 			if err != nil {
 				return err
 			}
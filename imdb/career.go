@@ -0,0 +1,254 @@
+package imdb
+
+import (
+	"sort"
+
+	"github.com/BurntSushi/csql"
+)
+
+// LeadBilling is the lowest billing position of a lead role. Credits billed
+// below it are supporting roles.
+const LeadBilling = 3
+
+// Career is a summary of the credits of an actor.
+// *Career satisfies the Attributer interface, but only for actors.
+//
+// Most of the summary is about titles, which are the movies and TV shows that
+// the actor is credited in. A credit in an episode is counted as a credit in
+// its TV show, so that appearing in hundreds of episodes of a soap opera
+// doesn't outweigh the rest of a career.
+type Career struct {
+	// Credits is the number of credits of the actor, where every episode
+	// counts. Titles is the number of movies and TV shows they are credited
+	// in.
+	Credits, Titles int
+
+	// Years has the number of titles that the actor is credited in for every
+	// year from their first credit to their last credit, including the years
+	// without any. Peak is the most titles in any one year.
+	Years []CareerYear
+	Peak  int
+
+	// First and Last are the earliest and latest credits of the actor that
+	// have a year. They are not valid if no credit has a year.
+	First, Last Credit
+
+	// MeanRank is the average user rank of the titles that have one, and
+	// Ranked is the number of those titles.
+	MeanRank float64
+	Ranked   int
+
+	// Genres are the genres of the titles, sorted by the number of titles in
+	// descending order and then by name.
+	Genres []CareerGenre
+
+	// Lead, Supporting and Unbilled are the number of titles in which the
+	// best billing position of the actor is at most LeadBilling, is lower
+	// than LeadBilling or is missing, respectively.
+	Lead, Supporting, Unbilled int
+
+	// TvRoles are the characters played by the actor in more than one
+	// episode of the same TV show, sorted by the number of episodes in
+	// descending order.
+	TvRoles []TvRole
+}
+
+// CareerYear is the number of titles that an actor is credited in for a year.
+type CareerYear struct {
+	Year, Titles int
+}
+
+// CareerGenre is the number of titles of a genre that an actor is credited in.
+type CareerGenre struct {
+	Name   string
+	Titles int
+}
+
+// TvRole is a character played by an actor in episodes of a TV show.
+type TvRole struct {
+	Tvshow    *Tvshow
+	Character string
+	Episodes  int
+
+	// First and Last are the years of the first and last episodes with the
+	// character. They are zero if no episode has a year.
+	First, Last int
+}
+
+// Len is the number of credits summarized by the career.
+func (c *Career) Len() int { return c.Credits }
+
+// careerTitles is a query for the titles of an actor, as described by Career.
+// The only parameter is the atom of the actor.
+const careerTitles = `
+	SELECT COALESCE(episode.tvshow_atom_id, credit.media_atom_id)
+	FROM credit
+	LEFT JOIN episode ON episode.atom_id = credit.media_atom_id
+	WHERE credit.actor_atom_id = $1
+`
+
+// ForEntity fills 'c' with a summary of the credits of the entity given,
+// which must be an actor.
+func (c *Career) ForEntity(db csql.Queryer, e Entity) (err error) {
+	defer csql.Safe(&err)
+
+	actor, ok := e.(*Actor)
+	if !ok {
+		return ef("Cannot summarize the career of '%s', which is not an "+
+			"actor.", e)
+	}
+	*c = Career{}
+
+	type credit struct {
+		media, title Atom
+		name         string
+		year         int
+		episode      int
+		character    string
+		position     int
+		attrs        string
+	}
+	var credits []credit
+	rows := csql.Query(db, `
+		SELECT
+			credit.media_atom_id,
+			COALESCE(episode.tvshow_atom_id, credit.media_atom_id),
+			COALESCE(name.name, ''),
+			COALESCE(movie.year, tvshow.year, episode.year, 0),
+			CASE WHEN episode.atom_id IS NULL THEN 0 ELSE 1 END,
+			credit.character, credit.position, credit.attrs
+		FROM credit
+		LEFT JOIN name ON name.atom_id = credit.media_atom_id
+		LEFT JOIN movie ON movie.atom_id = credit.media_atom_id
+		LEFT JOIN tvshow ON tvshow.atom_id = credit.media_atom_id
+		LEFT JOIN episode ON episode.atom_id = credit.media_atom_id
+		WHERE credit.actor_atom_id = $1
+		`, actor.Id)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var cr credit
+		csql.Scan(scanner, &cr.media, &cr.title, &cr.name, &cr.year,
+			&cr.episode, &cr.character, &cr.position, &cr.attrs)
+		credits = append(credits, cr)
+	})
+	c.Credits = len(credits)
+
+	// The best billing of the actor in each title, where 0 is unbilled.
+	billing := make(map[Atom]int)
+	perYear := make(map[int]map[Atom]bool)
+	var first, last *credit
+	type roleKey struct {
+		tvshow    Atom
+		character string
+	}
+	roles := make(map[roleKey]*TvRole)
+	var roleOrder []roleKey
+	for i := range credits {
+		cr := &credits[i]
+		best, ok := billing[cr.title]
+		if !ok || (cr.position > 0 && (best == 0 || cr.position < best)) {
+			billing[cr.title] = cr.position
+		}
+		if cr.year > 0 {
+			if perYear[cr.year] == nil {
+				perYear[cr.year] = make(map[Atom]bool)
+			}
+			perYear[cr.year][cr.title] = true
+			if first == nil || cr.year < first.year ||
+				(cr.year == first.year && cr.name < first.name) {
+				first = cr
+			}
+			if last == nil || cr.year > last.year ||
+				(cr.year == last.year && cr.name < last.name) {
+				last = cr
+			}
+		}
+		if cr.episode == 1 {
+			key := roleKey{cr.title, cr.character}
+			role, ok := roles[key]
+			if !ok {
+				role = &TvRole{Character: cr.character}
+				roles[key] = role
+				roleOrder = append(roleOrder, key)
+			}
+			role.Episodes++
+			if cr.year > 0 && (role.First == 0 || cr.year < role.First) {
+				role.First = cr.year
+			}
+			if cr.year > role.Last {
+				role.Last = cr.year
+			}
+		}
+	}
+
+	c.Titles = len(billing)
+	for _, best := range billing {
+		switch {
+		case best == 0:
+			c.Unbilled++
+		case best <= LeadBilling:
+			c.Lead++
+		default:
+			c.Supporting++
+		}
+	}
+	if first != nil {
+		for year := first.year; year <= last.year; year++ {
+			n := len(perYear[year])
+			c.Years = append(c.Years, CareerYear{year, n})
+			if n > c.Peak {
+				c.Peak = n
+			}
+		}
+		toCredit := func(cr *credit) Credit {
+			media, err := FromAtomGuess(db, cr.media)
+			csql.Panic(err)
+			return Credit{actor, media, cr.character, cr.position, cr.attrs}
+		}
+		c.First, c.Last = toCredit(first), toCredit(last)
+	}
+	for _, key := range roleOrder {
+		role := roles[key]
+		if role.Episodes < 2 {
+			continue
+		}
+		role.Tvshow, err = atomToTvshow(db, key.tvshow)
+		csql.Panic(err)
+		c.TvRoles = append(c.TvRoles, *role)
+	}
+	sort.Sort(tvRolesByEpisodes(c.TvRoles))
+
+	rows = csql.Query(db, sf(`
+		SELECT name, COUNT(DISTINCT atom_id) AS titles
+		FROM genre
+		WHERE atom_id IN (%s)
+		GROUP BY name
+		ORDER BY titles DESC, name ASC
+		`, careerTitles), actor.Id)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var g CareerGenre
+		csql.Scan(scanner, &g.Name, &g.Titles)
+		c.Genres = append(c.Genres, g)
+	})
+	csql.Scan(db.QueryRow(sf(`
+		SELECT COUNT(*), COALESCE(AVG(rank), 0)
+		FROM rating
+		WHERE votes > 0 AND atom_id IN (%s)
+		`, careerTitles), actor.Id), &c.Ranked, &c.MeanRank)
+	return nil
+}
+
+// tvRolesByEpisodes sorts TV roles by their number of episodes in descending
+// order, then by the year of their first episode and then by character.
+type tvRolesByEpisodes []TvRole
+
+func (rs tvRolesByEpisodes) Len() int      { return len(rs) }
+func (rs tvRolesByEpisodes) Swap(i, j int) { rs[i], rs[j] = rs[j], rs[i] }
+func (rs tvRolesByEpisodes) Less(i, j int) bool {
+	if rs[i].Episodes != rs[j].Episodes {
+		return rs[i].Episodes > rs[j].Episodes
+	}
+	if rs[i].First != rs[j].First {
+		return rs[i].First < rs[j].First
+	}
+	return rs[i].Character < rs[j].Character
+}
@@ -0,0 +1,215 @@
+package imdb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCareer(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	actor, err := FromAtom(db, EntityActor, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Career
+	if err := c.ForEntity(db, actor); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every episode is a credit, but only its TV show is a title.
+	if c.Credits != 11 || c.Titles != 6 {
+		t.Errorf("Expected 11 credits in 6 titles but got %d in %d.",
+			c.Credits, c.Titles)
+	}
+	// The best billing in each title counts, even in episodes.
+	if c.Lead != 3 || c.Supporting != 2 || c.Unbilled != 1 {
+		t.Errorf("Expected 3 lead, 2 supporting and 1 unbilled titles, but "+
+			"got %d, %d and %d.", c.Lead, c.Supporting, c.Unbilled)
+	}
+
+	// Years without credits are included, and titles without a year aren't
+	// counted.
+	years := []CareerYear{
+		{1988, 1}, {1989, 0}, {1990, 0}, {1991, 1}, {1992, 1}, {1993, 2},
+		{1994, 1}, {1995, 1},
+	}
+	if !reflect.DeepEqual(c.Years, years) || c.Peak != 2 {
+		t.Errorf("Expected years %v with a peak of 2 but got %v with %d.",
+			years, c.Years, c.Peak)
+	}
+	if c.First.Media.Ident() != 10 || c.Last.Media.Ident() != 22 {
+		t.Errorf("Expected the first and last credits in 10 and 22, but "+
+			"got %d and %d.", c.First.Media.Ident(), c.Last.Media.Ident())
+	}
+	if c.Last.Character != "Doc" {
+		t.Errorf("Expected the last credit as Doc but got %s.",
+			c.Last.Character)
+	}
+
+	genres := []CareerGenre{{"drama", 3}, {"comedy", 2}}
+	if !reflect.DeepEqual(c.Genres, genres) {
+		t.Errorf("Expected genres %v but got %v.", genres, c.Genres)
+	}
+	// Titles without votes and the ranks of episodes aren't counted.
+	if c.Ranked != 3 || c.MeanRank != 70 {
+		t.Errorf("Expected a mean rank of 70 over 3 titles but got %f "+
+			"over %d.", c.MeanRank, c.Ranked)
+	}
+
+	// A character in a single episode isn't a TV role.
+	type role struct {
+		tvshow      Atom
+		character   string
+		episodes    int
+		first, last int
+	}
+	var roles []role
+	for _, r := range c.TvRoles {
+		roles = append(roles, role{
+			r.Tvshow.Id, r.Character, r.Episodes, r.First, r.Last,
+		})
+	}
+	wantRoles := []role{{20, "Doc", 3, 1994, 1995}, {30, "Cop", 2, 1991, 1992}}
+	if !reflect.DeepEqual(roles, wantRoles) {
+		t.Errorf("Expected TV roles %v but got %v.", wantRoles, roles)
+	}
+
+	movie, err := FromAtom(db, EntityMovie, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ForEntity(db, movie); err == nil {
+		t.Errorf("Expected an error for the career of a movie.")
+	}
+}
+
+// testSQLiteDB returns a new SQLite database with the credits of a single
+// actor, along with a function that removes it.
+//
+// The actor (1) is credited in movies 10 to 13, in episodes 21 to 24 of TV
+// show 20 and in episodes 31 and 32 of TV show 30.
+func testSQLiteDB(t *testing.T) (*DB, func()) {
+	dir, err := ioutil.TempDir("", "goim-imdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Open("sqlite3", filepath.Join(dir, "goim.sqlite"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	type stmt struct {
+		q    string
+		args []interface{}
+	}
+	var stmts []stmt
+	add := func(q string, args ...interface{}) {
+		stmts = append(stmts, stmt{q, args})
+	}
+	name := func(id Atom, name string) {
+		add("INSERT INTO atom (id, hash) VALUES ($1, $2)", id, []byte(name))
+		add("INSERT INTO name (atom_id, name, search_key) VALUES ($1, $2, $3)",
+			id, name, NameKey(name))
+	}
+	rating := func(id Atom, votes, rank int) {
+		add("INSERT INTO rating (atom_id, votes, rank) VALUES ($1, $2, $3)",
+			id, votes, rank)
+	}
+	genre := func(id Atom, name string) {
+		add("INSERT INTO genre (atom_id, name) VALUES ($1, $2)", id, name)
+	}
+
+	name(1, "Star, A")
+	add("INSERT INTO actor (atom_id, sequence) VALUES (1, '')")
+
+	movies := []struct {
+		id    Atom
+		title string
+		year  int
+	}{
+		{10, "M1", 1988},
+		{11, "M2", 1993},
+		{12, "M3", 1993},
+		{13, "M4", 0},
+	}
+	for _, m := range movies {
+		name(m.id, m.title)
+		add("INSERT INTO movie (atom_id, year, sequence, tv, video) "+
+			"VALUES ($1, $2, '', 0, 0)", m.id, m.year)
+	}
+	for _, id := range []Atom{20, 30} {
+		name(id, sf("S%d", id))
+		add("INSERT INTO tvshow "+
+			"(atom_id, year, sequence, year_start, year_end) "+
+			"VALUES ($1, 1991, '', 1991, 1995)", id)
+	}
+	episodes := []struct {
+		id, tvshow Atom
+		year       int
+	}{
+		{21, 20, 1994},
+		{22, 20, 1995},
+		{23, 20, 1995},
+		{24, 20, 1995},
+		{31, 30, 1991},
+		{32, 30, 1992},
+	}
+	for _, e := range episodes {
+		name(e.id, sf("E%d", e.id))
+		add("INSERT INTO episode "+
+			"(atom_id, tvshow_atom_id, year, season, episode_num) "+
+			"VALUES ($1, $2, $3, 1, $4)", e.id, e.tvshow, e.year, e.id)
+	}
+
+	genre(10, "drama")
+	genre(10, "comedy")
+	genre(11, "drama")
+	genre(12, "drama")
+	genre(20, "comedy")
+	rating(10, 100, 80)
+	rating(11, 100, 60)
+	rating(12, 0, 0)
+	rating(20, 50, 70)
+	rating(21, 100, 10)
+
+	credits := []struct {
+		media     Atom
+		character string
+		position  int
+	}{
+		{10, "Hero", 1},
+		{11, "Twin", 5},
+		{11, "Other Twin", 2},
+		{12, "Friend", 7},
+		{13, "Extra", 0},
+		{21, "Doc", 0},
+		{22, "Doc", 0},
+		{23, "Doc", 4},
+		{24, "Guest", 0},
+		{31, "Cop", 3},
+		{32, "Cop", 0},
+	}
+	for _, c := range credits {
+		add("INSERT INTO credit "+
+			"(actor_atom_id, media_atom_id, character, position, attrs) "+
+			"VALUES (1, $1, $2, $3, '')", c.media, c.character, c.position)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt.q, stmt.args...); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return db, cleanup
+}
@@ -3,6 +3,7 @@ package imdb
 import (
 	"database/sql"
 	"fmt"
+	"sync"
 
 	_ "github.com/lib/pq"
 
@@ -26,6 +27,12 @@ type DB struct {
 	// For example, PostgreSQL supports simultaneous transactions updating the
 	// database but SQLite does not.
 	Driver string
+
+	// Whether fuzzy searching and full-text search are enabled and whether
+	// every name has a search key are cached, since finding out requires a
+	// query. (See IsFuzzyEnabled, IsTextSearchEnabled and HasNameKeys.)
+	mu                          sync.Mutex
+	fuzzy, textSearch, nameKeys *bool
 }
 
 // Open opens a connection to an IMDb relational database. The driver may
@@ -48,7 +55,7 @@ func Open(driver, dsn string) (*DB, error) {
 			return nil, fmt.Errorf("Could not set timezone to UTC: %s", err)
 		}
 	}
-	return &DB{db, driver}, nil
+	return &DB{DB: db, Driver: driver}, nil
 }
 
 // Close closes the connection to the database.
@@ -89,9 +96,65 @@ func (db *DB) Tables() (tables []string, err error) {
 	return
 }
 
+// TextSearchConfig is the PostgreSQL text search configuration used for
+// full-text search of plots, quotes, trivia, goofs and taglines.
+const TextSearchConfig = "english"
+
+// TextSearchTable returns the name of the SQLite FTS5 table that indexes the
+// text of the table given for full-text search.
+func TextSearchTable(table string) string {
+	return sf("fts_%s", table)
+}
+
+// IsTextSearchEnabled returns true if and only if full-text search of plots,
+// quotes, trivia, goofs and taglines is available. This is always true for
+// PostgreSQL. For SQLite, it requires the FTS5 extension.
+//
+// The answer is only looked up once for each database.
+func (db *DB) IsTextSearchEnabled() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.textSearch == nil {
+		enabled := db.textSearchEnabled()
+		db.textSearch = &enabled
+	}
+	return *db.textSearch
+}
+
+func (db *DB) textSearchEnabled() bool {
+	if db.Driver == "postgres" {
+		return true
+	}
+	var used int
+	q := "SELECT sqlite_compileoption_used('ENABLE_FTS5')"
+	if err := db.QueryRow(q).Scan(&used); err != nil {
+		return false
+	}
+	return used == 1
+}
+
 // IsFuzzyEnabled returns true if and only if the database is a Postgres
-// database with the 'pg_trgm' extension enabled.
+// database with the 'pg_trgm' extension enabled, or a SQLite database with
+// an index of name trigrams. (See IndexTrigrams.)
+//
+// The answer is only looked up once for each database, or again after the
+// trigram index is rebuilt.
 func (db *DB) IsFuzzyEnabled() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.fuzzy == nil {
+		enabled := db.fuzzyEnabled()
+		db.fuzzy = &enabled
+	}
+	return *db.fuzzy
+}
+
+func (db *DB) fuzzyEnabled() bool {
+	if db.Driver == "sqlite3" {
+		var one int
+		q := "SELECT 1 FROM name_trigram LIMIT 1"
+		return db.QueryRow(q).Scan(&one) == nil
+	}
 	_, err := db.Exec("SELECT similarity('a', 'a')")
 	if err == nil {
 		return true
@@ -1,6 +1,8 @@
 package imdb
 
 import (
+	"time"
+
 	"github.com/BurntSushi/csql"
 )
 
@@ -94,11 +96,11 @@ func FromAtom(db csql.Queryer, ent EntityKind, id Atom) (Entity, error) {
 	return nil, ef("Unrecognized entity type: %s", ent)
 }
 
-// fromAtomGuess is just like FromAtom, except it doesn't use an entity type
+// FromAtomGuess is just like FromAtom, except it doesn't use an entity type
 // as a hint for which table to select from. Therefore, it tries all entity
 // types until it gets a hit. If no entities could be found matching the
 // identifier given, an error is returned.
-func fromAtomGuess(db csql.Queryer, id Atom) (e Entity, err error) {
+func FromAtomGuess(db csql.Queryer, id Atom) (e Entity, err error) {
 	e, err = atomToMovie(db, id)
 	if err == nil {
 		return e, nil
@@ -148,6 +150,11 @@ type Episode struct {
 	Title              string
 	Year               int
 	Season, EpisodeNum int // May be 0!
+
+	// AirDate is the date the episode first aired, or the zero time if it
+	// isn't known. It comes from a date in the episode's identifier (common
+	// for daily shows) or from the earliest release date of the episode.
+	AirDate time.Time
 }
 
 // Actor represents a single cast member that has appeared in the credits of
@@ -156,6 +163,10 @@ type Actor struct {
 	Id       Atom
 	FullName string
 	Sequence string // Non-data. Used by IMDb for unique entity strings.
+
+	// Gender is "female" for actors from the actresses list and "male" for
+	// actors from the actors list. It is empty if it isn't known.
+	Gender string
 }
 
 func entityString(title string, year int) string {
@@ -226,15 +237,23 @@ func (e *Episode) Scan(rs csql.RowScanner) error {
 	if e == nil {
 		e = new(Episode)
 	}
-	return rs.Scan(&e.Id, &e.TvshowId, &e.Title,
-		&e.Year, &e.Season, &e.EpisodeNum)
+	var aired *time.Time
+	err := rs.Scan(&e.Id, &e.TvshowId, &e.Title,
+		&e.Year, &e.Season, &e.EpisodeNum, &aired)
+	if err != nil {
+		return err
+	}
+	if aired != nil {
+		e.AirDate = *aired
+	}
+	return nil
 }
 
 func (e *Actor) Scan(rs csql.RowScanner) error {
 	if e == nil {
 		e = new(Actor)
 	}
-	return rs.Scan(&e.Id, &e.FullName, &e.Sequence)
+	return rs.Scan(&e.Id, &e.FullName, &e.Sequence, &e.Gender)
 }
 
 func atomToMovie(db csql.Queryer, id Atom) (*Movie, error) {
@@ -259,22 +278,24 @@ func atomToTvshow(db csql.Queryer, id Atom) (*Tvshow, error) {
 	return e, err
 }
 
+// episodeQuery selects the columns read by Episode.Scan.
+const episodeQuery = `
+	SELECT e.atom_id, e.tvshow_atom_id, n.name,
+		   e.year, e.season, e.episode_num, e.aired
+	FROM episode AS e
+	LEFT JOIN name AS n ON n.atom_id = e.atom_id
+`
+
 func atomToEpisode(db csql.Queryer, id Atom) (*Episode, error) {
 	e := new(Episode)
-	err := e.Scan(db.QueryRow(`
-		SELECT e.atom_id, e.tvshow_atom_id, n.name,
-			   e.year, e.season, e.episode_num
-		FROM episode AS e
-		LEFT JOIN name AS n ON n.atom_id = e.atom_id
-		WHERE e.atom_id = $1
-		`, id))
+	err := e.Scan(db.QueryRow(episodeQuery+"WHERE e.atom_id = $1", id))
 	return e, err
 }
 
 func atomToActor(db csql.Queryer, id Atom) (*Actor, error) {
 	e := new(Actor)
 	err := e.Scan(db.QueryRow(`
-		SELECT a.atom_id, n.name, a.sequence
+		SELECT a.atom_id, n.name, a.sequence, a.gender
 		FROM actor AS a
 		LEFT JOIN name AS n ON n.atom_id = a.atom_id
 		WHERE a.atom_id = $1
@@ -282,6 +303,22 @@ func atomToActor(db csql.Queryer, id Atom) (*Actor, error) {
 	return e, err
 }
 
+// Episodes returns every episode of the TV show, sorted by season and episode
+// number.
+func (e *Tvshow) Episodes(db csql.Queryer) (eps []*Episode, err error) {
+	defer csql.Safe(&err)
+	rows := csql.Query(db, episodeQuery+`
+		WHERE e.tvshow_atom_id = $1
+		ORDER BY e.season ASC, e.episode_num ASC
+		`, e.Id)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		ep := new(Episode)
+		csql.Panic(ep.Scan(scanner))
+		eps = append(eps, ep)
+	})
+	return
+}
+
 // Tvshow returns a TV show entity that corresponds to this episode.
 func (e *Episode) Tvshow(db csql.Queryer) (*Tvshow, error) {
 	return atomToTvshow(db, e.TvshowId)
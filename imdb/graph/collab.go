@@ -0,0 +1,193 @@
+package graph
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/csql"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+// Together returns every direct connection between two actors or between two
+// media, as hops from a to b. (i.e., every movie, TV show and episode that two
+// actors are both credited in, or every actor credited in both of two media.)
+// Only credits permitted by the options given are used, except that the media
+// given are never excluded.
+//
+// Hops between actors are sorted by the year of their media in descending
+// order and then by name. Hops between media are sorted by the billing
+// position of their actors in a and then by name.
+func Together(
+	db *imdb.DB,
+	a, b imdb.Entity,
+	opts Options,
+) (hops []Hop, err error) {
+	defer csql.Safe(&err)
+
+	actors := a.Type() == imdb.EntityActor
+	if actors != (b.Type() == imdb.EntityActor) {
+		return nil, ef("Cannot find credits shared by an actor and media: "+
+			"'%s' and '%s'.", a, b)
+	}
+	if a.Ident() == b.Ident() {
+		return nil, ef("Cannot find credits shared by '%s' and itself.", a)
+	}
+	column, shared := "media_atom_id", "actor_atom_id"
+	if actors {
+		column, shared = "actor_atom_id", "media_atom_id"
+	}
+
+	g := &graph{db, opts, []imdb.Atom{a.Ident(), b.Ident()}}
+	conds := []string{
+		sf("c1.%s = $1", column),
+		sf("c2.%s = $2", column),
+	}
+	conds = append(conds, g.filters("c1")...)
+	conds = append(conds, g.filters("c2")...)
+
+	var pairs [][2]credit
+	rows := csql.Query(db, sf(`
+		SELECT
+			c1.actor_atom_id, c1.media_atom_id,
+			c1.character, c1.position, c1.attrs,
+			c2.actor_atom_id, c2.media_atom_id,
+			c2.character, c2.position, c2.attrs
+		FROM credit AS c1
+		INNER JOIN credit AS c2 ON c1.%s = c2.%s
+		WHERE %s
+		`, shared, shared, strings.Join(conds, " AND ")),
+		a.Ident(), b.Ident())
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var c1, c2 credit
+		csql.Scan(scanner,
+			&c1.actor, &c1.media, &c1.character, &c1.position, &c1.attrs,
+			&c2.actor, &c2.media, &c2.character, &c2.position, &c2.attrs)
+		pairs = append(pairs, [2]credit{c1, c2})
+	})
+
+	ents := map[imdb.Atom]imdb.Entity{a.Ident(): a, b.Ident(): b}
+	for _, pair := range pairs {
+		hop := Hop{
+			From:       a,
+			To:         b,
+			FromCredit: g.credit(ents, pair[0]),
+			ToCredit:   g.credit(ents, pair[1]),
+		}
+		if actors {
+			hop.Via = hop.FromCredit.Media
+		} else {
+			hop.Via = hop.FromCredit.Actor
+		}
+		hops = append(hops, hop)
+	}
+	if actors {
+		sort.Sort(hopsByYear(hops))
+	} else {
+		sort.Sort(hopsByBilling(hops))
+	}
+	return hops, nil
+}
+
+// Costar is an actor that shares credits with another actor.
+type Costar struct {
+	Actor *imdb.Actor
+
+	// Shared is the number of movies, TV shows and episodes that both actors
+	// are credited in.
+	Shared int
+
+	// Billing is the highest billing position of the co-star in any of the
+	// shared media, and MeanBilling is their average billing position. Both
+	// are zero if the co-star is never billed in the shared media.
+	Billing     int
+	MeanBilling float64
+}
+
+// Costars returns the actors that share the most credits with the actor
+// given, where only credits permitted by the options given are counted.
+// Co-stars are sorted by the number of shared credits in descending order,
+// then by their highest billing position. At most limit co-stars are
+// returned, unless limit is negative.
+func Costars(
+	db *imdb.DB,
+	actor *imdb.Actor,
+	opts Options,
+	limit int,
+) (costars []Costar, err error) {
+	defer csql.Safe(&err)
+
+	g := &graph{db, opts, []imdb.Atom{actor.Id}}
+	conds := []string{"c1.actor_atom_id = $1", "c2.actor_atom_id <> $1"}
+	conds = append(conds, g.filters("c1")...)
+	conds = append(conds, g.filters("c2")...)
+	q := sf(`
+		SELECT
+			c2.actor_atom_id,
+			COUNT(DISTINCT c2.media_atom_id) AS shared,
+			COALESCE(MIN(CASE WHEN c2.position > 0 THEN c2.position END), 0),
+			COALESCE(AVG(CASE WHEN c2.position > 0 THEN c2.position END), 0)
+		FROM credit AS c1
+		INNER JOIN credit AS c2 ON c1.media_atom_id = c2.media_atom_id
+		WHERE %s
+		GROUP BY c2.actor_atom_id
+		ORDER BY
+			shared DESC,
+			MIN(CASE WHEN c2.position > 0 THEN c2.position
+				ELSE 2147483647 END) ASC,
+			c2.actor_atom_id ASC
+		`, strings.Join(conds, " AND "))
+	if limit >= 0 {
+		q += sf(" LIMIT %d", limit)
+	}
+
+	var ids []imdb.Atom
+	rows := csql.Query(db, q, actor.Id)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var id imdb.Atom
+		var c Costar
+		csql.Scan(scanner, &id, &c.Shared, &c.Billing, &c.MeanBilling)
+		ids = append(ids, id)
+		costars = append(costars, c)
+	})
+	for i, id := range ids {
+		ent, err := imdb.FromAtom(db, imdb.EntityActor, id)
+		csql.Panic(err)
+		costars[i].Actor = ent.(*imdb.Actor)
+	}
+	return costars, nil
+}
+
+// hopsByYear sorts hops between actors by the year of the media they share
+// in descending order, where media without a year come last.
+type hopsByYear []Hop
+
+func (hs hopsByYear) Len() int      { return len(hs) }
+func (hs hopsByYear) Swap(i, j int) { hs[i], hs[j] = hs[j], hs[i] }
+func (hs hopsByYear) Less(i, j int) bool {
+	iyear, jyear := hs[i].Via.EntityYear(), hs[j].Via.EntityYear()
+	if iyear != jyear {
+		if iyear == 0 || jyear == 0 {
+			return jyear == 0
+		}
+		return iyear > jyear
+	}
+	return hs[i].Via.Name() < hs[j].Via.Name()
+}
+
+// hopsByBilling sorts hops between media by the billing position of the
+// actors they share in ascending order, where unbilled actors come last.
+type hopsByBilling []Hop
+
+func (hs hopsByBilling) Len() int      { return len(hs) }
+func (hs hopsByBilling) Swap(i, j int) { hs[i], hs[j] = hs[j], hs[i] }
+func (hs hopsByBilling) Less(i, j int) bool {
+	ibill, jbill := hs[i].FromCredit.Position, hs[j].FromCredit.Position
+	if ibill != jbill {
+		if ibill == 0 || jbill == 0 {
+			return jbill == 0
+		}
+		return ibill < jbill
+	}
+	return hs[i].Via.Name() < hs[j].Via.Name()
+}
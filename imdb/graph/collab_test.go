@@ -0,0 +1,116 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+func TestTogether(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		a, b imdb.Atom
+		opts Options
+		vias []imdb.Atom
+	}{
+		// By year in descending order, then by name, with no year last.
+		{6, 7, Options{}, []imdb.Atom{19, 20, 18, 21}},
+		{7, 6, Options{}, []imdb.Atom{19, 20, 18, 21}},
+		{6, 7, Options{NoArchive: true}, []imdb.Atom{19, 20, 21}},
+		{6, 9, Options{}, []imdb.Atom{20, 21}},
+		{8, 9, Options{}, nil},
+		// By billing in the first media, with unbilled actors last.
+		{18, 19, Options{}, []imdb.Atom{7, 6, 8}},
+		{19, 18, Options{}, []imdb.Atom{6, 7, 8}},
+		{20, 21, Options{}, []imdb.Atom{6, 9, 7}},
+	}
+	for _, test := range tests {
+		a, err := imdb.FromAtomGuess(db, test.a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, err := imdb.FromAtomGuess(db, test.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hops, err := Together(db, a, b, test.opts)
+		if err != nil {
+			t.Errorf("Could not find credits shared by %d and %d: %s",
+				test.a, test.b, err)
+			continue
+		}
+		var vias []imdb.Atom
+		for _, hop := range hops {
+			if hop.From.Ident() != test.a || hop.To.Ident() != test.b {
+				t.Errorf("Expected a hop from %d to %d but got %d to %d.",
+					test.a, test.b, hop.From.Ident(), hop.To.Ident())
+			}
+			vias = append(vias, hop.Via.Ident())
+		}
+		if !reflect.DeepEqual(vias, test.vias) {
+			t.Errorf("Expected %d and %d with %+v to share %v but got %v.",
+				test.a, test.b, test.opts, test.vias, vias)
+		}
+	}
+
+	f, _ := imdb.FromAtomGuess(db, 6)
+	m, _ := imdb.FromAtomGuess(db, 18)
+	if _, err := Together(db, f, m, Options{}); err == nil {
+		t.Errorf("Expected an error for credits shared by an actor and media.")
+	}
+	if _, err := Together(db, f, f, Options{}); err == nil {
+		t.Errorf("Expected an error for credits shared with itself.")
+	}
+}
+
+func TestCostars(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	type costar struct {
+		id      imdb.Atom
+		shared  int
+		billing int
+		mean    float64
+	}
+	tests := []struct {
+		actor   imdb.Atom
+		opts    Options
+		limit   int
+		costars []costar
+	}{
+		// By shared credits in descending order, then by best billing,
+		// with unbilled actors last.
+		{6, Options{}, -1,
+			[]costar{{7, 4, 1, 2}, {9, 2, 1, 2.5}, {8, 2, 0, 0}}},
+		{6, Options{}, 1, []costar{{7, 4, 1, 2}}},
+		{6, Options{NoArchive: true}, -1,
+			[]costar{{7, 3, 2, 2.5}, {9, 2, 1, 2.5}, {8, 2, 0, 0}}},
+		{9, Options{}, -1, []costar{{6, 2, 1, 1}, {7, 2, 2, 2}}},
+		{5, Options{}, -1, nil},
+	}
+	for _, test := range tests {
+		ent, err := imdb.FromAtom(db, imdb.EntityActor, test.actor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cs, err := Costars(db, ent.(*imdb.Actor), test.opts, test.limit)
+		if err != nil {
+			t.Errorf("Could not find co-stars of %d: %s", test.actor, err)
+			continue
+		}
+		var got []costar
+		for _, c := range cs {
+			got = append(got,
+				costar{c.Actor.Id, c.Shared, c.Billing, c.MeanBilling})
+		}
+		if !reflect.DeepEqual(got, test.costars) {
+			t.Errorf("Expected the co-stars of %d with %+v (limit %d) to be "+
+				"%v but got %v.", test.actor, test.opts, test.limit,
+				test.costars, got)
+		}
+	}
+}
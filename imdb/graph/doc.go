@@ -0,0 +1,30 @@
+/*
+Package graph provides queries on the graph of IMDb credits loaded with Goim,
+where actors are connected to the movies, TV shows and episodes that they are
+credited in.
+
+For example, the shortest path between two actors is found with ShortestPath:
+
+	path, err := ShortestPath(db, kevinBacon, tomHanks, Options{})
+
+Each step of the path (a Hop) connects two actors by a movie that they both
+appeared in. Paths between two movies (or TV shows or episodes) are found in
+the same way, where each step connects two movies by an actor that appeared
+in both.
+
+Together finds every credit shared by two actors (or every actor shared by two
+media), and Costars ranks the actors that most often share credits with an
+actor.
+
+FindFranchise follows the links between movies (sequels, prequels, remakes and
+spin offs) to find every movie in a franchise, in order of release and in
+order of their story.
+
+CreditNetwork builds the network of credits of some actors and media (or its
+projection onto actors), which can be written in the DOT, GraphML or GEXF
+formats for analysis with other tools.
+
+The graph is never loaded into memory. Instead, it is explored with one query
+per step, so that only the parts of the graph that are visited are read.
+*/
+package graph
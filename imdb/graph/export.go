@@ -0,0 +1,405 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"sort"
+
+	"github.com/BurntSushi/csql"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+// UnbilledPosition is the billing position used to weigh credits that aren't
+// billed. It is lower than the billing position of nearly every billed
+// credit.
+const UnbilledPosition = 100
+
+// Network is the graph of credits between some actors and media, in a form
+// that can be exported for analysis with other tools.
+//
+// A network returned by CreditNetwork is bipartite: every edge connects an
+// actor to media that they are credited in. Its projection onto actors
+// (see Actors) only has actors, where every edge connects two actors that are
+// credited in the same media.
+type Network struct {
+	Nodes []Node
+
+	// Edges are sorted by the nodes they connect. In a bipartite network,
+	// every edge is from an actor to media.
+	Edges []Edge
+}
+
+// Node is an actor, movie, TV show or episode in a network.
+type Node struct {
+	Id   imdb.Atom
+	Kind imdb.EntityKind
+	Name string
+
+	// Year, Rank and Votes are zero for actors and for media where they
+	// aren't known. Rank is the user rank from 1 to 100.
+	Year, Rank, Votes int
+
+	// Gender is "female" or "male" for actors. It is empty for media and for
+	// actors whose gender isn't known.
+	Gender string
+}
+
+func (n Node) String() string {
+	if n.Year > 0 {
+		return sf("%s (%d)", n.Name, n.Year)
+	}
+	return n.Name
+}
+
+// Edge connects two nodes in a network.
+type Edge struct {
+	From, To imdb.Atom
+
+	// Weight is computed from billing. An actor billed at position N in
+	// media is connected to it with a weight of 1/N, where unbilled credits
+	// are weighed as if billed at UnbilledPosition. (If an actor has more
+	// than one credit in the same media, then their highest billing is
+	// used.) Two actors are connected with the sum of the products of their
+	// weights in every media they share.
+	Weight float64
+
+	// Credits is the number of credits of an actor in media, or the number
+	// of media shared by two actors.
+	Credits int
+}
+
+// CreditNetwork returns the bipartite network of the actors and media given
+// and their credits. Every actor given is connected to every movie, TV show
+// and episode that they are credited in, and every media given is connected
+// to every actor credited in it. Only credits permitted by the options given
+// are used, except that credits in the media given are never excluded.
+func CreditNetwork(
+	db *imdb.DB,
+	actors, media []imdb.Atom,
+	opts Options,
+) (n *Network, err error) {
+	defer csql.Safe(&err)
+
+	g := &graph{db, opts, media}
+	n = new(Network)
+	seen := make(map[imdb.Atom]bool)
+	var ids []imdb.Atom
+	addNode := func(id imdb.Atom) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range actors {
+		addNode(id)
+	}
+	for _, id := range media {
+		addNode(id)
+	}
+
+	credits := make(map[credit]bool)
+	edges := make(map[[2]imdb.Atom]int)
+	addCredit := func(c credit) {
+		if credits[c] {
+			return
+		}
+		credits[c] = true
+		addNode(c.actor)
+		addNode(c.media)
+
+		key := [2]imdb.Atom{c.actor, c.media}
+		i, ok := edges[key]
+		if !ok {
+			i = len(n.Edges)
+			edges[key] = i
+			n.Edges = append(n.Edges, Edge{From: c.actor, To: c.media})
+		}
+		if w := billingWeight(c.position); w > n.Edges[i].Weight {
+			n.Edges[i].Weight = w
+		}
+		n.Edges[i].Credits++
+	}
+	addCredits := func(atoms []imdb.Atom, actors bool) {
+		for start := 0; start < len(atoms); start += batchSize {
+			end := start + batchSize
+			if end > len(atoms) {
+				end = len(atoms)
+			}
+			for _, c := range g.credits(atoms[start:end], actors) {
+				addCredit(c)
+			}
+		}
+	}
+	addCredits(actors, true)
+	addCredits(media, false)
+	n.Nodes = g.nodes(ids)
+	sort.Sort(edgesByNodes(n.Edges))
+	return n, nil
+}
+
+// Actors returns the projection of a bipartite network onto its actors. Two
+// actors are connected when they are credited in the same media of the
+// network, and media are not in the projection.
+func (n *Network) Actors() *Network {
+	cast := make(map[imdb.Atom][]Edge)
+	for _, e := range n.Edges {
+		cast[e.To] = append(cast[e.To], e)
+	}
+
+	proj := new(Network)
+	edges := make(map[[2]imdb.Atom]int)
+	for _, node := range n.Nodes {
+		if node.Kind == imdb.EntityActor {
+			proj.Nodes = append(proj.Nodes, node)
+			continue
+		}
+		es := cast[node.Id]
+		for i := range es {
+			for j := i + 1; j < len(es); j++ {
+				a, b := es[i].From, es[j].From
+				if a > b {
+					a, b = b, a
+				}
+				k, ok := edges[[2]imdb.Atom{a, b}]
+				if !ok {
+					k = len(proj.Edges)
+					edges[[2]imdb.Atom{a, b}] = k
+					proj.Edges = append(proj.Edges, Edge{From: a, To: b})
+				}
+				proj.Edges[k].Weight += es[i].Weight * es[j].Weight
+				proj.Edges[k].Credits++
+			}
+		}
+	}
+	sort.Sort(edgesByNodes(proj.Edges))
+	return proj
+}
+
+// WriteDOT writes the network as an undirected graph in the Graphviz DOT
+// language. Media are drawn as boxes and actors as ellipses. Every node has
+// its attributes (e.g., year or gender) and every edge has its weight and
+// number of credits. The weight is written as 'billing_weight', since the
+// 'weight' attribute of Graphviz must be an integer.
+func (n *Network) WriteDOT(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString("graph credits {\n")
+	for _, node := range n.Nodes {
+		attrs := sf("label=%s", dotQuote(node.String()))
+		if node.Kind != imdb.EntityActor {
+			attrs += ", shape=box"
+		}
+		for _, attr := range nodeAttrs {
+			if v := attr.value(node); len(v) > 0 {
+				if attr.typ == "string" {
+					v = dotQuote(v)
+				}
+				attrs += sf(", %s=%s", attr.name, v)
+			}
+		}
+		buf.WriteString(sf("\t%d [%s];\n", node.Id, attrs))
+	}
+	for _, e := range n.Edges {
+		buf.WriteString(sf("\t%d -- %d [billing_weight=%g, credits=%d];\n",
+			e.From, e.To, e.Weight, e.Credits))
+	}
+	buf.WriteString("}\n")
+	return buf.Flush()
+}
+
+// WriteGraphML writes the network as an undirected graph in the GraphML
+// format, with the same attributes as WriteDOT.
+func (n *Network) WriteGraphML(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString(xml.Header)
+	buf.WriteString(
+		`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	buf.WriteString("  <key id=\"label\" for=\"node\" " +
+		"attr.name=\"label\" attr.type=\"string\"/>\n")
+	for _, attr := range nodeAttrs {
+		buf.WriteString(sf("  <key id=\"%s\" for=\"node\" "+
+			"attr.name=\"%s\" attr.type=\"%s\"/>\n",
+			attr.name, attr.name, attr.typ))
+	}
+	buf.WriteString("  <key id=\"weight\" for=\"edge\" " +
+		"attr.name=\"weight\" attr.type=\"double\"/>\n")
+	buf.WriteString("  <key id=\"credits\" for=\"edge\" " +
+		"attr.name=\"credits\" attr.type=\"int\"/>\n")
+	buf.WriteString(`  <graph id="credits" edgedefault="undirected">` + "\n")
+	for _, node := range n.Nodes {
+		buf.WriteString(sf("    <node id=\"%d\">\n", node.Id))
+		buf.WriteString(sf("      <data key=\"label\">%s</data>\n",
+			xmlEscape(node.String())))
+		for _, attr := range nodeAttrs {
+			if v := attr.value(node); len(v) > 0 {
+				buf.WriteString(sf("      <data key=\"%s\">%s</data>\n",
+					attr.name, xmlEscape(v)))
+			}
+		}
+		buf.WriteString("    </node>\n")
+	}
+	for _, e := range n.Edges {
+		buf.WriteString(sf("    <edge source=\"%d\" target=\"%d\">\n",
+			e.From, e.To))
+		buf.WriteString(sf("      <data key=\"weight\">%g</data>\n", e.Weight))
+		buf.WriteString(sf("      <data key=\"credits\">%d</data>\n",
+			e.Credits))
+		buf.WriteString("    </edge>\n")
+	}
+	buf.WriteString("  </graph>\n")
+	buf.WriteString("</graphml>\n")
+	return buf.Flush()
+}
+
+// WriteGEXF writes the network as an undirected graph in the GEXF format
+// (version 1.2), with the same attributes as WriteDOT.
+func (n *Network) WriteGEXF(w io.Writer) error {
+	gexfTypes := map[string]string{"string": "string", "int": "integer"}
+
+	buf := bufio.NewWriter(w)
+	buf.WriteString(xml.Header)
+	buf.WriteString(`<gexf xmlns="http://www.gexf.net/1.2draft" ` +
+		`version="1.2">` + "\n")
+	buf.WriteString(`  <graph defaultedgetype="undirected">` + "\n")
+	buf.WriteString(`    <attributes class="node">` + "\n")
+	for _, attr := range nodeAttrs {
+		buf.WriteString(sf("      <attribute id=\"%s\" title=\"%s\" "+
+			"type=\"%s\"/>\n", attr.name, attr.name, gexfTypes[attr.typ]))
+	}
+	buf.WriteString("    </attributes>\n")
+	buf.WriteString(`    <attributes class="edge">` + "\n")
+	buf.WriteString("      <attribute id=\"credits\" title=\"credits\" " +
+		"type=\"integer\"/>\n")
+	buf.WriteString("    </attributes>\n")
+
+	buf.WriteString("    <nodes>\n")
+	for _, node := range n.Nodes {
+		buf.WriteString(sf("      <node id=\"%d\" label=\"%s\">\n",
+			node.Id, xmlEscape(node.String())))
+		buf.WriteString("        <attvalues>\n")
+		for _, attr := range nodeAttrs {
+			if v := attr.value(node); len(v) > 0 {
+				buf.WriteString(sf("          <attvalue for=\"%s\" "+
+					"value=\"%s\"/>\n", attr.name, xmlEscape(v)))
+			}
+		}
+		buf.WriteString("        </attvalues>\n")
+		buf.WriteString("      </node>\n")
+	}
+	buf.WriteString("    </nodes>\n")
+
+	buf.WriteString("    <edges>\n")
+	for i, e := range n.Edges {
+		buf.WriteString(sf("      <edge id=\"%d\" source=\"%d\" "+
+			"target=\"%d\" weight=\"%g\">\n", i, e.From, e.To, e.Weight))
+		buf.WriteString(sf("        <attvalues><attvalue for=\"credits\" "+
+			"value=\"%d\"/></attvalues>\n", e.Credits))
+		buf.WriteString("      </edge>\n")
+	}
+	buf.WriteString("    </edges>\n")
+	buf.WriteString("  </graph>\n")
+	buf.WriteString("</gexf>\n")
+	return buf.Flush()
+}
+
+// nodeAttrs are the attributes of nodes written by every format. The type of
+// an attribute is "string" or "int", and an empty value is omitted. (The user
+// rank isn't called "rank", which is a Graphviz attribute.)
+var nodeAttrs = []struct {
+	name, typ string
+	value     func(n Node) string
+}{
+	{"kind", "string", func(n Node) string { return n.Kind.String() }},
+	{"year", "int", func(n Node) string { return nonzero(n.Year) }},
+	{"user_rank", "int", func(n Node) string { return nonzero(n.Rank) }},
+	{"votes", "int", func(n Node) string { return nonzero(n.Votes) }},
+	{"gender", "string", func(n Node) string { return n.Gender }},
+}
+
+// nodes returns the nodes of the entities given, in the same order. Database
+// errors cause a panic.
+func (g *graph) nodes(ids []imdb.Atom) []Node {
+	byId := make(map[imdb.Atom]Node, len(ids))
+	for start := 0; start < len(ids); start += batchSize {
+		end := start + batchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		rows := csql.Query(g.db, sf(`
+			SELECT
+				atom.id,
+				CASE
+					WHEN movie.atom_id IS NOT NULL THEN 'movie'
+					WHEN tvshow.atom_id IS NOT NULL THEN 'tvshow'
+					WHEN episode.atom_id IS NOT NULL THEN 'episode'
+					ELSE 'actor'
+				END,
+				COALESCE(name.name, ''),
+				COALESCE(movie.year, tvshow.year, episode.year, 0),
+				COALESCE(rating.rank, 0), COALESCE(rating.votes, 0),
+				COALESCE(actor.gender, '')
+			FROM atom
+			LEFT JOIN name ON name.atom_id = atom.id
+			LEFT JOIN movie ON movie.atom_id = atom.id
+			LEFT JOIN tvshow ON tvshow.atom_id = atom.id
+			LEFT JOIN episode ON episode.atom_id = atom.id
+			LEFT JOIN actor ON actor.atom_id = atom.id
+			LEFT JOIN rating ON rating.atom_id = atom.id
+			WHERE atom.id IN (%s)
+			`, atomList(ids[start:end])))
+		csql.ForRow(rows, func(scanner csql.RowScanner) {
+			var node Node
+			var kind string
+			csql.Scan(scanner, &node.Id, &kind, &node.Name,
+				&node.Year, &node.Rank, &node.Votes, &node.Gender)
+			node.Kind = imdb.Entities[kind]
+			byId[node.Id] = node
+		})
+	}
+
+	nodes := make([]Node, 0, len(ids))
+	for _, id := range ids {
+		if node, ok := byId[id]; ok {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// billingWeight returns the weight of a credit with the billing position
+// given. (See Edge.Weight.)
+func billingWeight(position int) float64 {
+	if position <= 0 {
+		position = UnbilledPosition
+	}
+	return 1 / float64(position)
+}
+
+// nonzero formats an integer, or returns an empty string if it is zero.
+func nonzero(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return sf("%d", n)
+}
+
+// xmlEscape returns a string escaped for use in XML text and attributes.
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// edgesByNodes sorts edges by the nodes they connect.
+type edgesByNodes []Edge
+
+func (es edgesByNodes) Len() int      { return len(es) }
+func (es edgesByNodes) Swap(i, j int) { es[i], es[j] = es[j], es[i] }
+func (es edgesByNodes) Less(i, j int) bool {
+	if es[i].From != es[j].From {
+		return es[i].From < es[j].From
+	}
+	return es[i].To < es[j].To
+}
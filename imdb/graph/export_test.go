@@ -0,0 +1,131 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+func TestActors(t *testing.T) {
+	actor := func(id imdb.Atom) Node {
+		return Node{Id: id, Kind: imdb.EntityActor}
+	}
+	movie := func(id imdb.Atom) Node {
+		return Node{Id: id, Kind: imdb.EntityMovie}
+	}
+	n := &Network{
+		Nodes: []Node{actor(1), actor(2), actor(3), movie(10), movie(11)},
+		Edges: []Edge{
+			{1, 10, billingWeight(1), 1},
+			{1, 11, billingWeight(1), 1},
+			{2, 10, billingWeight(2), 2},
+			{2, 11, billingWeight(4), 1},
+			{3, 10, billingWeight(0), 1},
+		},
+	}
+	want := []Edge{
+		{1, 2, 1.0/2 + 1.0/4, 2},
+		{1, 3, 1.0 / UnbilledPosition, 1},
+		{2, 3, 1.0 / 2 / UnbilledPosition, 1},
+	}
+
+	proj := n.Actors()
+	if len(proj.Nodes) != 3 {
+		t.Errorf("Expected the 3 actors in the projection but got %v",
+			proj.Nodes)
+	}
+	for _, node := range proj.Nodes {
+		if node.Kind != imdb.EntityActor {
+			t.Errorf("Expected only actors in the projection but got %v", node)
+		}
+	}
+	if len(proj.Edges) != len(want) {
+		t.Fatalf("Expected edges %v but got %v", want, proj.Edges)
+	}
+	for i, e := range proj.Edges {
+		w := want[i]
+		if e.From != w.From || e.To != w.To || e.Credits != w.Credits ||
+			math.Abs(e.Weight-w.Weight) > 1e-9 {
+			t.Errorf("Expected edge %v but got %v", w, e)
+		}
+	}
+}
+
+func TestExportEscaping(t *testing.T) {
+	name := `Say "Hi" \ & <Co>`
+	n := &Network{
+		Nodes: []Node{
+			{Id: 1, Kind: imdb.EntityActor, Name: name, Gender: "female"},
+			{Id: 2, Kind: imdb.EntityMovie, Name: "M&M's", Year: 2001},
+		},
+		Edges: []Edge{{1, 2, 0.5, 1}},
+	}
+
+	var dot bytes.Buffer
+	if err := n.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`1 [label="Say \"Hi\" \\ & <Co>", kind="actor", gender="female"];`,
+		`2 [label="M&M's (2001)", shape=box, kind="movie", year=2001];`,
+		`1 -- 2 [billing_weight=0.5, credits=1];`,
+	} {
+		if !strings.Contains(dot.String(), "\t"+line+"\n") {
+			t.Errorf("Expected DOT line\n%s\nin\n%s", line, dot.String())
+		}
+	}
+
+	formats := []struct {
+		name  string
+		write func(w io.Writer) error
+	}{
+		{"GraphML", n.WriteGraphML},
+		{"GEXF", n.WriteGEXF},
+	}
+	for _, format := range formats {
+		var buf bytes.Buffer
+		if err := format.write(&buf); err != nil {
+			t.Fatal(err)
+		}
+		texts, err := xmlTexts(buf.Bytes())
+		if err != nil {
+			t.Errorf("%s is not well formed: %s\n%s",
+				format.name, err, buf.String())
+			continue
+		}
+		for _, label := range []string{name, "M&M's (2001)"} {
+			if !texts[label] {
+				t.Errorf("Expected label '%s' in %s:\n%s",
+					label, format.name, buf.String())
+			}
+		}
+	}
+}
+
+// xmlTexts returns the set of every attribute value and every piece of
+// character data in an XML document.
+func xmlTexts(doc []byte) (map[string]bool, error) {
+	texts := make(map[string]bool)
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return texts, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			for _, attr := range tok.Attr {
+				texts[attr.Value] = true
+			}
+		case xml.CharData:
+			texts[string(tok)] = true
+		}
+	}
+}
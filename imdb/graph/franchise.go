@@ -0,0 +1,276 @@
+package graph
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/csql"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+// franchiseLink describes how a type of link is followed by FindFranchise.
+// Every link is converted to the equivalent link from the original media to
+// the media derived from it (e.g., "B follows A" becomes "A followed by B").
+type franchiseLink struct {
+	typ     string
+	reverse bool
+}
+
+// franchiseLinks are the types of links in the link table that are followed
+// by FindFranchise.
+var franchiseLinks = map[string]franchiseLink{
+	"followed by":   {"followed by", false},
+	"follows":       {"followed by", true},
+	"remade as":     {"remade as", false},
+	"remake of":     {"remade as", true},
+	"spin off":      {"spin off", false},
+	"spin off from": {"spin off", true},
+}
+
+// Franchise is every movie, TV show and episode connected to some media by
+// sequels, prequels, remakes and spin offs.
+type Franchise struct {
+	// Start is the media that the franchise was found from.
+	Start imdb.Entity
+
+	// Media is every entity in the franchise in order of release.
+	Media []imdb.Entity
+
+	// Chronological is every entity in the franchise in the order of its
+	// story, as given by the "followed by" links between them. Entities that
+	// aren't ordered by those links are in order of release. If the links
+	// contradict each other, then the order can't be determined and
+	// Chronological is nil.
+	Chronological []imdb.Entity
+
+	// Links are the links between the entities in the franchise, sorted in
+	// the order of release of the entities they link.
+	Links []FranchiseLink
+}
+
+// FranchiseLink is a link between two entities in a franchise. Its type is
+// "followed by", "remade as" or "spin off", so that To is always derived from
+// From.
+type FranchiseLink struct {
+	From imdb.Entity
+	Type string
+	To   imdb.Entity
+}
+
+func (lk FranchiseLink) String() string {
+	return sf("%s %s %s", lk.From, lk.Type, lk.To)
+}
+
+// FindFranchise returns the franchise of the media given by following the
+// "follows", "followed by", "remake of", "remade as", "spin off" and
+// "spin off from" links from it, and from every entity linked to it, and so
+// on. Links are followed in both directions, so that links without an inverse
+// in the database are still found. If the media isn't linked to anything,
+// then its franchise is only itself.
+func FindFranchise(db *imdb.DB, media imdb.Entity) (f *Franchise, err error) {
+	defer csql.Safe(&err)
+
+	var types []string
+	for typ := range franchiseLinks {
+		types = append(types, sf("'%s'", typ))
+	}
+	sort.Strings(types)
+
+	type edge struct {
+		from, to imdb.Atom
+		typ      string
+	}
+	seen := map[imdb.Atom]bool{media.Ident(): true}
+	edges := make(map[edge]bool)
+	var order []edge
+	frontier := []imdb.Atom{media.Ident()}
+	for len(frontier) > 0 {
+		var next []imdb.Atom
+		for start := 0; start < len(frontier); start += batchSize {
+			end := start + batchSize
+			if end > len(frontier) {
+				end = len(frontier)
+			}
+			ids := atomList(frontier[start:end])
+			rows := csql.Query(db, sf(`
+				SELECT atom_id, link_type, link_atom_id
+				FROM link
+				WHERE atom_id IN (%s) AND link_type IN (%s)
+				UNION
+				SELECT atom_id, link_type, link_atom_id
+				FROM link
+				WHERE link_atom_id IN (%s) AND link_type IN (%s)
+				`, ids, strings.Join(types, ", "),
+				ids, strings.Join(types, ", ")))
+			csql.ForRow(rows, func(scanner csql.RowScanner) {
+				var e edge
+				var typ string
+				csql.Scan(scanner, &e.from, &typ, &e.to)
+				flk := franchiseLinks[typ]
+				e.typ = flk.typ
+				if flk.reverse {
+					e.from, e.to = e.to, e.from
+				}
+				if e.from == e.to || edges[e] {
+					return
+				}
+				edges[e] = true
+				order = append(order, e)
+				for _, id := range []imdb.Atom{e.from, e.to} {
+					if !seen[id] {
+						seen[id] = true
+						next = append(next, id)
+					}
+				}
+			})
+		}
+		frontier = next
+	}
+
+	ents := map[imdb.Atom]imdb.Entity{media.Ident(): media}
+	f = &Franchise{Start: media}
+	for id := range seen {
+		if _, ok := ents[id]; !ok {
+			ent, err := imdb.FromAtomGuess(db, id)
+			csql.Panic(err)
+			ents[id] = ent
+		}
+		f.Media = append(f.Media, ents[id])
+	}
+	sort.Sort(byRelease(f.Media))
+
+	release := make(map[imdb.Atom]int, len(f.Media))
+	for i, ent := range f.Media {
+		release[ent.Ident()] = i
+	}
+	for _, e := range order {
+		lk := FranchiseLink{ents[e.from], e.typ, ents[e.to]}
+		f.Links = append(f.Links, lk)
+	}
+	sort.Sort(linksByRelease{f.Links, release})
+	f.Chronological = f.chronological(release)
+	return f, nil
+}
+
+// chronological returns the entities of the franchise in order of their
+// story. (See Franchise.Chronological.) release maps each entity to its
+// position in the order of release.
+//
+// This is a topological sort of the "followed by" links, where the entity
+// released first is picked whenever there is more than one choice.
+func (f *Franchise) chronological(release map[imdb.Atom]int) []imdb.Entity {
+	before := make(map[imdb.Atom]int) // number of entities preceding
+	after := make(map[imdb.Atom][]imdb.Entity)
+	for _, lk := range f.Links {
+		if lk.Type != "followed by" {
+			continue
+		}
+		before[lk.To.Ident()]++
+		after[lk.From.Ident()] = append(after[lk.From.Ident()], lk.To)
+	}
+
+	var ready, order []imdb.Entity
+	for _, ent := range f.Media {
+		if before[ent.Ident()] == 0 {
+			ready = append(ready, ent)
+		}
+	}
+	for len(ready) > 0 {
+		first := 0
+		for i := range ready {
+			if release[ready[i].Ident()] < release[ready[first].Ident()] {
+				first = i
+			}
+		}
+		ent := ready[first]
+		ready = append(ready[:first], ready[first+1:]...)
+		order = append(order, ent)
+		for _, next := range after[ent.Ident()] {
+			before[next.Ident()]--
+			if before[next.Ident()] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(order) < len(f.Media) {
+		return nil
+	}
+	return order
+}
+
+// WriteDOT writes the franchise as a directed graph in the Graphviz DOT
+// language, where every entity is a node and every link is an edge labeled
+// with its type. The media that the franchise was found from is highlighted.
+func (f *Franchise) WriteDOT(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+	buf.WriteString("digraph franchise {\n")
+	buf.WriteString("\tnode [shape=box];\n")
+	for _, ent := range f.Media {
+		attrs := sf("label=%s", dotQuote(sf("%s", ent)))
+		if ent.Ident() == f.Start.Ident() {
+			attrs += ", style=bold"
+		}
+		buf.WriteString(sf("\t%d [%s];\n", ent.Ident(), attrs))
+	}
+	for _, lk := range f.Links {
+		buf.WriteString(sf("\t%d -> %d [label=%s];\n",
+			lk.From.Ident(), lk.To.Ident(), dotQuote(lk.Type)))
+	}
+	buf.WriteString("}\n")
+	return buf.Flush()
+}
+
+// dotQuote returns a string as a quoted DOT identifier.
+func dotQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
+
+// byRelease sorts entities by their year in ascending order, where entities
+// without a year (which usually haven't been released) come last. Ties are
+// broken by name.
+type byRelease []imdb.Entity
+
+func (es byRelease) Len() int      { return len(es) }
+func (es byRelease) Swap(i, j int) { es[i], es[j] = es[j], es[i] }
+func (es byRelease) Less(i, j int) bool {
+	iyear, jyear := es[i].EntityYear(), es[j].EntityYear()
+	if iyear != jyear {
+		if iyear == 0 || jyear == 0 {
+			return jyear == 0
+		}
+		return iyear < jyear
+	}
+	if es[i].Name() != es[j].Name() {
+		return es[i].Name() < es[j].Name()
+	}
+	return es[i].Ident() < es[j].Ident()
+}
+
+// linksByRelease sorts links by the order of release of the entities they
+// link, first by where they link from and then by where they link to.
+type linksByRelease struct {
+	links   []FranchiseLink
+	release map[imdb.Atom]int
+}
+
+func (lks linksByRelease) Len() int { return len(lks.links) }
+func (lks linksByRelease) Swap(i, j int) {
+	lks.links[i], lks.links[j] = lks.links[j], lks.links[i]
+}
+func (lks linksByRelease) Less(i, j int) bool {
+	li, lj := lks.links[i], lks.links[j]
+	fi, fj := lks.release[li.From.Ident()], lks.release[lj.From.Ident()]
+	if fi != fj {
+		return fi < fj
+	}
+	ti, tj := lks.release[li.To.Ident()], lks.release[lj.To.Ident()]
+	if ti != tj {
+		return ti < tj
+	}
+	return li.Type < lj.Type
+}
@@ -0,0 +1,67 @@
+package graph
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+func TestChronological(t *testing.T) {
+	// The media of the franchise are released in order of their atoms.
+	type link struct {
+		from imdb.Atom
+		typ  string
+		to   imdb.Atom
+	}
+	tests := []struct {
+		name  string
+		links []link
+		order []imdb.Atom // nil if the order can't be determined
+	}{
+		{"no links", nil, []imdb.Atom{1, 2, 3, 4}},
+		{"sequels", []link{{1, "followed by", 2}, {2, "followed by", 3}},
+			[]imdb.Atom{1, 2, 3, 4}},
+		{"prequel", []link{{3, "followed by", 1}},
+			[]imdb.Atom{2, 3, 1, 4}},
+		{"prequels of a sequel",
+			[]link{{4, "followed by", 2}, {3, "followed by", 2}},
+			[]imdb.Atom{1, 3, 4, 2}},
+		{"competing sequels",
+			[]link{{1, "followed by", 3}, {1, "followed by", 4},
+				{2, "followed by", 3}},
+			[]imdb.Atom{1, 2, 3, 4}},
+		{"other links", []link{{4, "remade as", 1}, {3, "spin off", 2}},
+			[]imdb.Atom{1, 2, 3, 4}},
+		{"contradiction", []link{{1, "followed by", 2}, {2, "followed by", 1}},
+			nil},
+		{"cycle",
+			[]link{{2, "followed by", 3}, {3, "followed by", 4},
+				{4, "followed by", 2}},
+			nil},
+	}
+	for _, test := range tests {
+		f := new(Franchise)
+		ents := make(map[imdb.Atom]imdb.Entity)
+		release := make(map[imdb.Atom]int)
+		for i, title := range []string{"A", "B", "C", "D"} {
+			m := &imdb.Movie{Id: imdb.Atom(i + 1), Title: title, Year: 2000 + i}
+			f.Media = append(f.Media, m)
+			ents[m.Id] = m
+			release[m.Id] = i
+		}
+		for _, lk := range test.links {
+			f.Links = append(f.Links,
+				FranchiseLink{ents[lk.from], lk.typ, ents[lk.to]})
+		}
+
+		var order []imdb.Atom
+		for _, ent := range f.chronological(release) {
+			order = append(order, ent.Ident())
+		}
+		if !reflect.DeepEqual(order, test.order) {
+			t.Errorf("%s: expected chronological order %v but got %v",
+				test.name, test.order, order)
+		}
+	}
+}
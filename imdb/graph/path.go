@@ -0,0 +1,312 @@
+package graph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/BurntSushi/csql"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+var (
+	sf = fmt.Sprintf
+	ef = fmt.Errorf
+)
+
+// Options restricts the credits that are followed when exploring the graph.
+// The zero value follows every credit.
+type Options struct {
+	// NoEpisodes excludes credits in TV episodes.
+	NoEpisodes bool
+
+	// MinVotes excludes credits in media with fewer user votes than given.
+	// When it is zero, media without any votes are included.
+	MinVotes int
+
+	// NoArchive excludes credits for archive footage. (i.e., appearances
+	// that were made with footage from other media.)
+	NoArchive bool
+
+	// MaxDegrees is the longest path to search for, in degrees of
+	// separation. When it is zero, paths of any length are searched for.
+	// It is only used by ShortestPath.
+	MaxDegrees int
+}
+
+// Path is a shortest path between two actors or between two media, through
+// the credits that connect them.
+type Path struct {
+	From, To imdb.Entity
+
+	// Hops are the steps of the path in order, where the first hop starts at
+	// From and the last hop ends at To. There are no hops when From and To
+	// are the same.
+	Hops []Hop
+}
+
+// Degrees returns the degrees of separation between the ends of the path.
+func (p *Path) Degrees() int {
+	return len(p.Hops)
+}
+
+// Hop is a single step in a path. When the path is between actors, then Via
+// is a movie, TV show or episode that both From and To are credited in. When
+// the path is between media, then Via is an actor that is credited in both
+// From and To.
+type Hop struct {
+	From, Via, To imdb.Entity
+
+	// FromCredit and ToCredit are the credits that connect From and To to
+	// Via, respectively.
+	FromCredit, ToCredit imdb.Credit
+}
+
+// ShortestPath finds the shortest path between two actors or between two
+// media, where actors and media are connected by their credits. Only credits
+// permitted by the options given are followed, except that the media at the
+// ends of the path are never excluded. If there is no such path, then a nil
+// path is returned.
+//
+// The path is found with a breadth first search from both ends at the same
+// time, where the end with the fewest entities to visit is always expanded
+// next. This keeps the number of credits read reasonably small even though
+// some actors (and media) have thousands of credits.
+func ShortestPath(
+	db *imdb.DB,
+	from, to imdb.Entity,
+	opts Options,
+) (p *Path, err error) {
+	defer csql.Safe(&err)
+
+	fromActor := from.Type() == imdb.EntityActor
+	if fromActor != (to.Type() == imdb.EntityActor) {
+		return nil, ef("Cannot find a path between an actor and media: "+
+			"'%s' and '%s'.", from, to)
+	}
+	if from.Ident() == to.Ident() {
+		return &Path{From: from, To: to}, nil
+	}
+
+	g := &graph{db, opts, []imdb.Atom{from.Ident(), to.Ident()}}
+	fwd, bwd := newSide(from.Ident(), fromActor), newSide(to.Ident(), fromActor)
+	for len(fwd.frontier) > 0 && len(bwd.frontier) > 0 {
+		// Every path of length 'fwd.depth + bwd.depth' or shorter has been
+		// ruled out, so the next step can only find a longer one.
+		// (The length of a path is twice its degrees of separation.)
+		if opts.MaxDegrees > 0 && fwd.depth+bwd.depth+1 > 2*opts.MaxDegrees {
+			break
+		}
+		var meet imdb.Atom
+		var found bool
+		if len(fwd.frontier) <= len(bwd.frontier) {
+			meet, found = g.expand(fwd, bwd)
+		} else {
+			meet, found = g.expand(bwd, fwd)
+		}
+		if found {
+			return g.path(from, to, fwd, bwd, meet), nil
+		}
+	}
+	return nil, nil
+}
+
+// batchSize is the maximum number of entities whose credits are read in a
+// single query.
+const batchSize = 500
+
+// graph is the credit graph of a database as restricted by a set of options.
+type graph struct {
+	db   *imdb.DB
+	opts Options
+
+	// ends are the entities that the graph is explored from, like the
+	// entities at each end of the path being searched for. Credits in ends
+	// are never excluded by the options.
+	ends []imdb.Atom
+}
+
+// credit is a credit as it is stored in the database.
+type credit struct {
+	actor, media imdb.Atom
+	character    string
+	position     int
+	attrs        string
+}
+
+// visit records how an entity was first reached by a breadth first search.
+type visit struct {
+	prev imdb.Atom // the entity that it was reached from
+	via  credit    // the credit connecting it to prev
+}
+
+// side is the state of the breadth first search from one end of a path.
+type side struct {
+	visited  map[imdb.Atom]visit
+	frontier []imdb.Atom
+	depth    int
+
+	// actors is true when the entities in the frontier are actors.
+	actors bool
+}
+
+func newSide(start imdb.Atom, actors bool) *side {
+	return &side{
+		visited:  map[imdb.Atom]visit{start: visit{}},
+		frontier: []imdb.Atom{start},
+		actors:   actors,
+	}
+}
+
+// expand visits every entity connected to the frontier of s that hasn't been
+// visited yet, and makes them the new frontier. If one of them has already
+// been visited by the other side, then the search stops and that entity is
+// returned.
+func (g *graph) expand(s, other *side) (meet imdb.Atom, found bool) {
+	var next []imdb.Atom
+	for start := 0; start < len(s.frontier); start += batchSize {
+		end := start + batchSize
+		if end > len(s.frontier) {
+			end = len(s.frontier)
+		}
+		for _, c := range g.credits(s.frontier[start:end], s.actors) {
+			prev, ent := c.media, c.actor
+			if s.actors {
+				prev, ent = c.actor, c.media
+			}
+			if _, ok := s.visited[ent]; ok {
+				continue
+			}
+			s.visited[ent] = visit{prev, c}
+			if _, ok := other.visited[ent]; ok {
+				return ent, true
+			}
+			next = append(next, ent)
+		}
+	}
+	s.frontier, s.depth, s.actors = next, s.depth+1, !s.actors
+	return 0, false
+}
+
+// credits returns every credit of the entities given that is permitted by
+// the options of the graph. Database errors cause a panic.
+func (g *graph) credits(ents []imdb.Atom, actors bool) []credit {
+	column := "media_atom_id"
+	if actors {
+		column = "actor_atom_id"
+	}
+	conds := []string{sf("credit.%s IN (%s)", column, atomList(ents))}
+	conds = append(conds, g.filters("credit")...)
+
+	var credits []credit
+	rows := csql.Query(g.db, sf(`
+		SELECT actor_atom_id, media_atom_id, character, position, attrs
+		FROM credit
+		WHERE %s
+		`, strings.Join(conds, " AND ")))
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var c credit
+		csql.Scan(scanner,
+			&c.actor, &c.media, &c.character, &c.position, &c.attrs)
+		credits = append(credits, c)
+	})
+	return credits
+}
+
+// filters returns the conditions that restrict the credits with the alias
+// given to those permitted by the options of the graph.
+func (g *graph) filters(alias string) []string {
+	var conds []string
+	if g.opts.NoArchive {
+		conds = append(conds,
+			sf("%s.attrs NOT LIKE '%%archive footage%%'", alias))
+	}
+
+	var media []string
+	if g.opts.NoEpisodes {
+		media = append(media, sf(
+			"%s.media_atom_id NOT IN (SELECT atom_id FROM episode)", alias))
+	}
+	if g.opts.MinVotes > 0 {
+		media = append(media, sf(
+			"%s.media_atom_id IN "+
+				"(SELECT atom_id FROM rating WHERE votes >= %d)",
+			alias, g.opts.MinVotes))
+	}
+	if len(media) > 0 && len(g.ends) == 0 {
+		conds = append(conds, media...)
+	} else if len(media) > 0 {
+		conds = append(conds, sf("(%s.media_atom_id IN (%s) OR (%s))",
+			alias, atomList(g.ends), strings.Join(media, " AND ")))
+	}
+	return conds
+}
+
+// path builds the path between from and to that passes through the entity
+// where the searches from both ends met. Database errors cause a panic.
+func (g *graph) path(
+	from, to imdb.Entity,
+	fwd, bwd *side,
+	meet imdb.Atom,
+) *Path {
+	var chain []credit
+	for ent := meet; ent != from.Ident(); ent = fwd.visited[ent].prev {
+		chain = append([]credit{fwd.visited[ent].via}, chain...)
+	}
+	for ent := meet; ent != to.Ident(); ent = bwd.visited[ent].prev {
+		chain = append(chain, bwd.visited[ent].via)
+	}
+
+	ents := map[imdb.Atom]imdb.Entity{from.Ident(): from, to.Ident(): to}
+	p := &Path{From: from, To: to}
+	cur := from
+	for i := 0; i+1 < len(chain); i += 2 {
+		fromCredit := g.credit(ents, chain[i])
+		toCredit := g.credit(ents, chain[i+1])
+		hop := Hop{From: cur, FromCredit: fromCredit, ToCredit: toCredit}
+		if cur.Type() == imdb.EntityActor {
+			hop.Via, hop.To = fromCredit.Media, toCredit.Actor
+		} else {
+			hop.Via, hop.To = fromCredit.Actor, toCredit.Media
+		}
+		p.Hops = append(p.Hops, hop)
+		cur = hop.To
+	}
+	return p
+}
+
+// credit converts a credit from the database to an imdb.Credit, where ents
+// caches the entities that have already been read.
+func (g *graph) credit(ents map[imdb.Atom]imdb.Entity, c credit) imdb.Credit {
+	actor, ok := ents[c.actor]
+	if !ok {
+		var err error
+		actor, err = imdb.FromAtom(g.db, imdb.EntityActor, c.actor)
+		csql.Panic(err)
+		ents[c.actor] = actor
+	}
+	media, ok := ents[c.media]
+	if !ok {
+		var err error
+		media, err = imdb.FromAtomGuess(g.db, c.media)
+		csql.Panic(err)
+		ents[c.media] = media
+	}
+	return imdb.Credit{
+		Actor:     actor.(*imdb.Actor),
+		Media:     media,
+		Character: c.character,
+		Position:  c.position,
+		Attrs:     c.attrs,
+	}
+}
+
+// atomList returns the atoms given as a comma separated list for use in an
+// SQL 'IN' expression.
+func atomList(atoms []imdb.Atom) string {
+	strs := make([]string, len(atoms))
+	for i, atom := range atoms {
+		strs[i] = atom.String()
+	}
+	return strings.Join(strs, ", ")
+}
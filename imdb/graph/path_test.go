@@ -0,0 +1,232 @@
+package graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+func TestShortestPath(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	noEps := Options{NoEpisodes: true}
+	noArchive := Options{NoArchive: true}
+	neither := Options{NoEpisodes: true, NoArchive: true}
+	tests := []struct {
+		from, to imdb.Atom
+		opts     Options
+		path     []imdb.Atom // the ends and every hop between, or nil
+	}{
+		{1, 1, Options{}, []imdb.Atom{1}},
+		// Direct co-stars.
+		{1, 2, Options{}, []imdb.Atom{1, 10, 2}},
+		{2, 1, Options{}, []imdb.Atom{2, 10, 1}},
+		{1, 3, Options{}, []imdb.Atom{1, 17, 3}},
+		{1, 4, Options{}, []imdb.Atom{1, 15, 4}},
+		// Two hops.
+		{1, 3, noArchive, []imdb.Atom{1, 10, 2, 11, 3}},
+		{1, 4, noEps, []imdb.Atom{1, 17, 3, 12, 4}},
+		{1, 4, neither, []imdb.Atom{1, 10, 2, 11, 3, 12, 4}},
+		// Movie 12 has too few votes.
+		{1, 4, Options{NoEpisodes: true, NoArchive: true, MinVotes: 100},
+			nil},
+		{1, 2, Options{MinVotes: 100}, []imdb.Atom{1, 10, 2}},
+		// The media at the ends are never excluded.
+		{12, 11, Options{MinVotes: 100}, []imdb.Atom{12, 3, 11}},
+		// The degree limit.
+		{1, 4, Options{NoEpisodes: true, NoArchive: true, MaxDegrees: 2},
+			nil},
+		{1, 4, Options{NoEpisodes: true, NoArchive: true, MaxDegrees: 3},
+			[]imdb.Atom{1, 10, 2, 11, 3, 12, 4}},
+		{1, 3, Options{NoArchive: true, MaxDegrees: 1}, nil},
+		// Unreachable.
+		{1, 5, Options{}, nil},
+		{10, 14, Options{}, nil},
+	}
+	for _, test := range tests {
+		from, err := imdb.FromAtomGuess(db, test.from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		to, err := imdb.FromAtomGuess(db, test.to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ShortestPath(db, from, to, test.opts)
+		if err != nil {
+			t.Errorf("Could not find a path from %d to %d: %s",
+				test.from, test.to, err)
+			continue
+		}
+		var got []imdb.Atom
+		if p != nil {
+			got = append(got, p.From.Ident())
+			for i, hop := range p.Hops {
+				if i == 0 && hop.From.Ident() != p.From.Ident() {
+					t.Errorf("The first hop from %d to %d starts at %d.",
+						test.from, test.to, hop.From.Ident())
+				}
+				got = append(got, hop.Via.Ident(), hop.To.Ident())
+			}
+			if p.Degrees() != len(p.Hops) {
+				t.Errorf("Expected %d degrees but got %d.",
+					len(p.Hops), p.Degrees())
+			}
+		}
+		if !reflect.DeepEqual(got, test.path) {
+			t.Errorf("Expected the path from %d to %d with %+v to be %v "+
+				"but got %v.", test.from, test.to, test.opts, test.path, got)
+		}
+	}
+
+	// The credits of each hop connect its ends to the entity between them.
+	a, _ := imdb.FromAtomGuess(db, 1)
+	c, _ := imdb.FromAtomGuess(db, 3)
+	p, err := ShortestPath(db, a, c, noArchive)
+	if err != nil || p == nil || len(p.Hops) != 2 {
+		t.Fatalf("Expected a path with 2 hops but got %v (%v).", p, err)
+	}
+	var chars []string
+	for _, hop := range p.Hops {
+		chars = append(chars,
+			hop.FromCredit.Character, hop.ToCredit.Character)
+	}
+	want := []string{"A in M1", "B in M1", "B in M2", "C in M2"}
+	if !reflect.DeepEqual(chars, want) {
+		t.Errorf("Expected the characters of the hops to be %v but got %v.",
+			want, chars)
+	}
+
+	m, _ := imdb.FromAtomGuess(db, 10)
+	if _, err := ShortestPath(db, a, m, Options{}); err == nil {
+		t.Errorf("Expected an error for a path between an actor and media.")
+	}
+}
+
+// testSQLiteDB returns a new SQLite database with a small graph of credits,
+// along with a function that removes it.
+//
+// Actors 1 to 4 (A to D) form a chain through movies 10, 11 and 12, which
+// only has a few votes. A is also credited with C in movie 17, where C only
+// appears in archive footage, and with D in episode 15 of TV show 16. Actor 5
+// (E) is only credited in movie 14.
+//
+// Actors 6 to 9 (F to I) only share credits with each other, in movies 18 to
+// 21 from different years and with different billing positions.
+func testSQLiteDB(t *testing.T) (*imdb.DB, func()) {
+	dir, err := ioutil.TempDir("", "goim-graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := imdb.Open("sqlite3", filepath.Join(dir, "goim.sqlite"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	type stmt struct {
+		q    string
+		args []interface{}
+	}
+	var stmts []stmt
+	add := func(q string, args ...interface{}) {
+		stmts = append(stmts, stmt{q, args})
+	}
+	name := func(id imdb.Atom, name string) {
+		add("INSERT INTO atom (id, hash) VALUES ($1, $2)", id, []byte(name))
+		add("INSERT INTO name (atom_id, name, search_key) VALUES ($1, $2, $3)",
+			id, name, imdb.NameKey(name))
+	}
+
+	actors := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I"}
+	for i, actor := range actors {
+		name(imdb.Atom(i+1), actor)
+		add("INSERT INTO actor (atom_id, sequence) VALUES ($1, '')", i+1)
+	}
+	movies := []struct {
+		id    imdb.Atom
+		title string
+		year  int
+		votes int
+	}{
+		{10, "M1", 2000, 1000},
+		{11, "M2", 2000, 1000},
+		{12, "M3", 2000, 10},
+		{14, "M4", 2000, 1000},
+		{17, "M5", 2000, 1000},
+		{18, "N1", 1990, 1000},
+		{19, "N2", 2005, 1000},
+		{20, "N3", 2005, 1000},
+		{21, "N4", 0, 1000},
+	}
+	for _, m := range movies {
+		name(m.id, m.title)
+		add("INSERT INTO movie (atom_id, year, sequence, tv, video) "+
+			"VALUES ($1, $2, '', 0, 0)", m.id, m.year)
+		add("INSERT INTO rating (atom_id, votes, rank) VALUES ($1, $2, 70)",
+			m.id, m.votes)
+	}
+	name(16, "S")
+	add("INSERT INTO tvshow (atom_id, year, sequence, year_start, year_end) " +
+		"VALUES (16, 2000, '', 2000, 2001)")
+	name(15, "E1")
+	add("INSERT INTO episode " +
+		"(atom_id, tvshow_atom_id, year, season, episode_num) " +
+		"VALUES (15, 16, 2000, 1, 1)")
+	add("INSERT INTO rating (atom_id, votes, rank) VALUES (15, 1000, 70)")
+
+	credits := []struct {
+		actor, media imdb.Atom
+		character    string
+		position     int
+		attrs        string
+	}{
+		{1, 10, "A in M1", 1, ""},
+		{2, 10, "B in M1", 1, ""},
+		{2, 11, "B in M2", 1, ""},
+		{3, 11, "C in M2", 1, ""},
+		{3, 12, "C in M3", 1, ""},
+		{4, 12, "D in M3", 1, ""},
+		{5, 14, "E in M4", 1, ""},
+		{1, 15, "A in E1", 1, ""},
+		{4, 15, "D in E1", 1, ""},
+		{1, 17, "A in M5", 1, ""},
+		{3, 17, "C in M5", 1, "(archive footage)"},
+
+		{6, 18, "F in N1", 2, ""},
+		{6, 19, "F in N2", 1, ""},
+		{6, 20, "F in N3", 1, ""},
+		{6, 21, "F in N4", 1, ""},
+		{7, 18, "G in N1", 1, "(archive footage)"},
+		{7, 19, "G in N2", 3, ""},
+		{7, 20, "G in N3", 2, ""},
+		{7, 21, "G in N4", 0, ""},
+		{8, 18, "H in N1", 0, ""},
+		{8, 19, "H in N2", 0, ""},
+		{9, 20, "I in N3", 1, ""},
+		{9, 21, "I in N4", 4, ""},
+	}
+	for _, c := range credits {
+		add("INSERT INTO credit "+
+			"(actor_atom_id, media_atom_id, character, position, attrs) "+
+			"VALUES ($1, $2, $3, $4, $5)",
+			c.actor, c.media, c.character, c.position, c.attrs)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt.q, stmt.args...); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return db, cleanup
+}
@@ -161,6 +161,50 @@ var migrations = map[string][]migration.Migrator{
 				`)
 			return err
 		},
+		func(tx migration.LimitedTx) error {
+			_, err := tx.Exec(`
+				ALTER TABLE rating
+				ADD COLUMN distribution TEXT NOT NULL DEFAULT '';
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			_, err := tx.Exec(`
+				ALTER TABLE episode
+				ADD COLUMN aired DATE;
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			// An index of the trigrams in every name, which is used for
+			// fuzzy searching. (PostgreSQL uses pg_trgm instead.)
+			_, err := tx.Exec(`
+				CREATE TABLE name_trigram (
+					atom_id INTEGER NOT NULL,
+					trigram TEXT NOT NULL,
+					total INTEGER NOT NULL
+				);
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			// The normalized name that search text is matched against.
+			// (See NameKey.)
+			_, err := tx.Exec(`
+				ALTER TABLE name
+				ADD COLUMN search_key TEXT NOT NULL DEFAULT '';
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			// The gender of an actor: 'female' for actors from the
+			// actresses list and 'male' for actors from the actors list.
+			_, err := tx.Exec(`
+				ALTER TABLE actor
+				ADD COLUMN gender TEXT NOT NULL DEFAULT '';
+				`)
+			return err
+		},
 	},
 	"postgres": {
 		func(tx migration.LimitedTx) error {
@@ -309,6 +353,38 @@ var migrations = map[string][]migration.Migrator{
 				`)
 			return err
 		},
+		func(tx migration.LimitedTx) error {
+			_, err := tx.Exec(`
+				ALTER TABLE rating
+				ADD COLUMN distribution TEXT NOT NULL DEFAULT '';
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			_, err := tx.Exec(`
+				ALTER TABLE episode
+				ADD COLUMN aired DATE;
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			// The normalized name that search text is matched against.
+			// (See NameKey.)
+			_, err := tx.Exec(`
+				ALTER TABLE name
+				ADD COLUMN search_key TEXT NOT NULL DEFAULT '';
+				`)
+			return err
+		},
+		func(tx migration.LimitedTx) error {
+			// The gender of an actor: 'female' for actors from the
+			// actresses list and 'male' for actors from the actors list.
+			_, err := tx.Exec(`
+				ALTER TABLE actor
+				ADD COLUMN gender TEXT NOT NULL DEFAULT '';
+				`)
+			return err
+		},
 	},
 }
 
@@ -316,7 +392,7 @@ type index struct {
 	unique   bool
 	table    string
 	name     string
-	fulltext string // empty, "gin" or "gist"
+	fulltext string // empty, "gin" or "gist" (trigram) or "text"
 	columns  []string
 }
 
@@ -324,6 +400,7 @@ var indices = []index{
 	{true, "atom", "", "", []string{"hash"}},
 	{false, "episode", "tv", "", []string{"tvshow_atom_id"}},
 	{false, "episode", "tvseason", "", []string{"tvshow_atom_id", "season"}},
+	{false, "episode", "", "", []string{"aired"}},
 
 	{false, "release_date", "", "", []string{"atom_id"}},
 	{false, "running_time", "", "", []string{"atom_id"}},
@@ -340,14 +417,31 @@ var indices = []index{
 	{false, "literature", "", "", []string{"atom_id"}},
 	{false, "location", "", "", []string{"atom_id"}},
 	{false, "link", "", "", []string{"atom_id"}},
+	{false, "link", "", "", []string{"link_atom_id"}},
 	{false, "plot", "", "", []string{"atom_id"}},
 	{false, "quote", "", "", []string{"atom_id"}},
 	{false, "rating", "", "", []string{"atom_id"}},
 	{false, "credit", "", "", []string{"actor_atom_id"}},
 	{false, "credit", "", "", []string{"media_atom_id"}},
 
-	{false, "name", "trgm_name", "gist", []string{"name"}},
+	{false, "name", "", "", []string{"search_key"}},
+
+	{false, "name", "trgm_name", "gist", []string{"search_key"}},
 	{false, "aka_title", "trgm_title", "gist", []string{"title"}},
+
+	// Full-text search indices. With SQLite, these are FTS5 tables named
+	// 'fts_TABLE' that use the indexed table as their content.
+	{false, "plot", "text", "text", []string{"entry"}},
+	{false, "quote", "text", "text", []string{"entry"}},
+	{false, "trivia", "text", "text", []string{"entry"}},
+	{false, "goof", "text", "text", []string{"entry"}},
+	{false, "tagline", "text", "text", []string{"tag"}},
+}
+
+// sqliteIndices are only created with SQLite, since the columns they index
+// are primary keys with PostgreSQL.
+var sqliteIndices = []index{
+	{false, "name", "", "", []string{"atom_id"}},
 }
 
 func (in index) sqlName() string {
@@ -365,12 +459,15 @@ func (in index) sqlName() string {
 }
 
 func (in index) sqlCreate(db *DB) string {
+	if in.isTextSearch() {
+		return in.sqlCreateText(db)
+	}
 	uni := ""
 	if in.unique {
 		uni = " UNIQUE "
 	}
 	using, class := "", ""
-	if in.isFulltext() {
+	if in.isTrigram() {
 		using = sf(" USING %s ", in.fulltext)
 		switch in.fulltext {
 		case "gin":
@@ -386,11 +483,39 @@ func (in index) sqlCreate(db *DB) string {
 		strings.Join(in.columns, ", "), class)
 }
 
-func (in index) isFulltext() bool {
-	return len(in.fulltext) > 0
+// sqlCreateText returns the SQL for creating a full-text search index. With
+// PostgreSQL, this is a GIN index on the text search vector of the column.
+// With SQLite, this is an external content FTS5 table, which is rebuilt from
+// scratch since it is not kept up to date automatically.
+func (in index) sqlCreateText(db *DB) string {
+	if len(in.columns) != 1 {
+		panic("full-text search indices must have exactly one column")
+	}
+	if db.Driver == "sqlite3" {
+		fts := TextSearchTable(in.table)
+		return sf(`
+			CREATE VIRTUAL TABLE %s USING fts5(
+				%s, content='%s', content_rowid='rowid'
+			);
+			INSERT INTO %s (%s) VALUES ('rebuild')`,
+			fts, in.columns[0], in.table, fts, fts)
+	}
+	return sf("CREATE INDEX %s ON %s USING gin (to_tsvector('%s', %s))",
+		in.sqlName(), in.table, TextSearchConfig, in.columns[0])
+}
+
+func (in index) isTrigram() bool {
+	return in.fulltext == "gin" || in.fulltext == "gist"
+}
+
+func (in index) isTextSearch() bool {
+	return in.fulltext == "text"
 }
 
 func (in index) sqlDrop(db *DB) string {
+	if in.isTextSearch() && db.Driver == "sqlite3" {
+		return sf("DROP TABLE IF EXISTS %s", TextSearchTable(in.table))
+	}
 	return sf("DROP INDEX IF EXISTS %s", in.sqlName())
 }
 
@@ -401,11 +526,23 @@ func doIndices(
 ) (err error) {
 	defer csql.Safe(&err)
 
-	trgmEnabled := db.IsFuzzyEnabled()
+	// Trigram indices are for PostgreSQL's pg_trgm extension. SQLite has its
+	// own trigram index. (See IndexTrigrams.)
+	trgmEnabled := db.Driver == "postgres" && db.IsFuzzyEnabled()
+	textEnabled := db.IsTextSearchEnabled()
+	all := indices
+	if db.Driver == "sqlite3" {
+		all = append(all[:len(all):len(all)], sqliteIndices...)
+	}
 	var q string
 	var ok bool
-	for _, idx := range indices {
-		if idx.isFulltext() && !trgmEnabled {
+	for _, idx := range all {
+		if idx.isTextSearch() && !textEnabled {
+			log.Printf("Skipping full-text search index on '%s' since "+
+				"SQLite was not compiled with FTS5.", idx.table)
+			continue
+		}
+		if idx.isTrigram() && !trgmEnabled {
 			// Only show the error message if we're on PostgreSQL.
 			if db.Driver == "postgres" {
 				log.Printf("Skipping fulltext index '%s' since "+
@@ -0,0 +1,176 @@
+package imdb
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/csql"
+)
+
+// foldings maps every accented letter in Latin-1 to the letters it is
+// folded to in search keys. (Names from IMDb's lists are always Latin-1.)
+var foldings = map[string]string{
+	"ÀÁÂÃÄÅàáâãäåª": "a",
+	"Ææ":            "ae",
+	"Çç":            "c",
+	"Ðð":            "d",
+	"ÈÉÊËèéêë":      "e",
+	"ÌÍÎÏìíîï":      "i",
+	"Ññ":            "n",
+	"ÒÓÔÕÖØòóôõöøº": "o",
+	"ÙÚÛÜùúûü":      "u",
+	"Ýýÿ":           "y",
+	"Þþ":            "th",
+	"ß":             "ss",
+}
+
+var runeFoldings = make(map[rune]string)
+
+func init() {
+	for from, to := range foldings {
+		for _, r := range from {
+			runeFoldings[r] = to
+		}
+	}
+}
+
+// articles are the leading articles that are ignored in search keys.
+var articles = map[string]bool{"the": true, "a": true, "an": true}
+
+// NameKey returns the search key of a name, which is what search text is
+// matched against. Two names that differ only in case, accents, punctuation
+// or a leading article have the same search key. For example, "Amélie",
+// "The Simpsons" and "Simpsons, The" have the keys "amelie", "simpsons" and
+// "simpsons".
+//
+// More precisely, letters are converted to lower case and stripped of their
+// accents, apostrophes are removed and every other character that isn't a
+// letter or a digit separates words. An article at the beginning of the name
+// (or at the end, after a comma) is then removed, unless it is the only word.
+// The words are joined with a single space.
+//
+// If a name has no letters or digits at all, then its key is the name in
+// lower case, so that the key of a non-empty name is never empty.
+func NameKey(name string) string {
+	name = strings.TrimSpace(name)
+	if i := strings.LastIndex(name, ","); i > -1 {
+		last := strings.TrimSpace(name[i+1:])
+		if articles[strings.ToLower(last)] {
+			name = last + " " + name[:i]
+		}
+	}
+
+	var folded []rune
+	for _, r := range name {
+		switch {
+		case r == '\'' || r == '`' || r == '´' || r == '’':
+			// Removed so that "Schindler's" and "Schindlers" are the same.
+		case runeFoldings[r] != "":
+			folded = append(folded, []rune(runeFoldings[r])...)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			folded = append(folded, unicode.ToLower(r))
+		default:
+			folded = append(folded, ' ')
+		}
+	}
+	words := strings.Fields(string(folded))
+	if len(words) > 1 && articles[words[0]] {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return strings.ToLower(name)
+	}
+	return strings.Join(words, " ")
+}
+
+// UpdateNameKeys sets the search key of every name that doesn't have one.
+// (See NameKey.) Names are given search keys when they are added, so this is
+// only needed for names that were added before search keys existed. This is
+// done automatically by 'goim load'.
+//
+// The number of names updated is returned.
+func (db *DB) UpdateNameKeys() (updated int, err error) {
+	defer csql.Safe(&err)
+
+	tx, err := db.Begin()
+	csql.Panic(err)
+
+	// Keys are computed in Go, so they are bulk inserted into a temporary
+	// table and then copied to the names with a single UPDATE.
+	csql.Exec(tx, `
+		CREATE TEMPORARY TABLE name_key (
+			atom_id INTEGER PRIMARY KEY,
+			search_key TEXT NOT NULL
+		)
+		`)
+	ins, err := csql.NewInserter(tx, db.Driver, "name_key",
+		"atom_id", "search_key")
+	csql.Panic(err)
+
+	// Names are read in batches so that they aren't all kept in memory and
+	// so that rows aren't being read while keys are inserted.
+	const batch = 10000
+	type name struct {
+		id   Atom
+		name string
+	}
+	last := Atom(0)
+	for {
+		var names []name
+		rows := csql.Query(tx, `
+			SELECT atom_id, name FROM name
+			WHERE atom_id > $1 AND search_key = ''
+			ORDER BY atom_id ASC
+			LIMIT $2
+			`, last, batch)
+		csql.ForRow(rows, func(scanner csql.RowScanner) {
+			var n name
+			csql.Scan(scanner, &n.id, &n.name)
+			names = append(names, n)
+		})
+		if len(names) == 0 {
+			break
+		}
+		for _, n := range names {
+			csql.Panic(ins.Exec(n.id, NameKey(n.name)))
+		}
+		updated += len(names)
+		last = names[len(names)-1].id
+	}
+	csql.Panic(ins.Exec()) // inserts anything left in the buffer
+	if updated > 0 {
+		csql.Exec(tx, `
+			UPDATE name
+			SET search_key = (
+				SELECT k.search_key FROM name_key AS k
+				WHERE k.atom_id = name.atom_id
+			)
+			WHERE atom_id IN (SELECT atom_id FROM name_key)
+			`)
+	}
+	csql.Exec(tx, "DROP TABLE name_key")
+	csql.Panic(tx.Commit())
+
+	db.mu.Lock()
+	db.nameKeys = nil
+	db.mu.Unlock()
+	return
+}
+
+// HasNameKeys returns true if and only if every name has a search key. This
+// is only false for databases created before search keys existed, until
+// UpdateNameKeys is called (e.g., by 'goim load').
+//
+// The answer is only looked up once for each database, or again after search
+// keys are updated.
+func (db *DB) HasNameKeys() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.nameKeys == nil {
+		var one int
+		q := "SELECT 1 FROM name WHERE search_key = '' LIMIT 1"
+		has := db.QueryRow(q).Scan(&one) != nil
+		db.nameKeys = &has
+	}
+	return *db.nameKeys
+}
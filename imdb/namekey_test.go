@@ -0,0 +1,36 @@
+package imdb
+
+import "testing"
+
+func TestNameKey(t *testing.T) {
+	tests := []struct {
+		name, key string
+	}{
+		{"The Matrix", "matrix"},
+		{"Matrix, The", "matrix"},
+		{"  An American Tail ", "american tail"},
+		{"Man Who Wasn't There, The", "man who wasnt there"},
+		{"Simpsons, A", "simpsons"},
+		{"The", "the"},
+		{"A", "a"},
+		{"Them", "them"},
+		{"Amélie", "amelie"},
+		{"ÅNGSTRÖM", "angstrom"},
+		{"Ærø Æsir", "aero aesir"},
+		{"Straße", "strasse"},
+		{"Þór", "thor"},
+		{"Schindler's List", "schindlers list"},
+		{"Schindler’s List", "schindlers list"},
+		{"Spider-Man: Homecoming", "spider man homecoming"},
+		{"2001: A Space Odyssey", "2001 a space odyssey"},
+		{"Ōkami", "ōkami"},
+		{"!!!", "!!!"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if key := NameKey(test.name); key != test.key {
+			t.Errorf("Expected the key of '%s' to be '%s' but got '%s'.",
+				test.name, test.key, key)
+		}
+	}
+}
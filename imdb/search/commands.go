@@ -3,6 +3,7 @@ package search
 import (
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/ty/fun"
 
@@ -71,6 +72,17 @@ var (
 	// represented as a map where keys are command names. (Synonyms are
 	// included in the keys.)
 	allCommands = map[string]command{}
+
+	// unfilterable is the set of commands that change how a search is run
+	// rather than which results are returned. They cannot be negated or used
+	// inside of groups.
+	unfilterable = map[string]bool{
+		"credits": true, "cast": true, "anycredits": true, "show": true,
+		"credits*": true, "cast*": true, "show*": true, "billing": true,
+		"debug": true, "similar": true, "weightvotes": true,
+		"weightmean": true, "releasecountry": true, "limit": true,
+		"offset": true, "sort": true,
+	}
 )
 
 func init() {
@@ -137,10 +149,99 @@ func init() {
 				return nil
 			},
 		},
+		{
+			"language", []string{"lang"}, true,
+			"Restricts results to only include media in the language " +
+				"given. Multiple languages will be combined disjunctively. " +
+				"e.g., {language:french}.",
+			func(s *Searcher, v string) error {
+				s.Language(v)
+				return nil
+			},
+		},
+		{
+			"sound", []string{"mix"}, true,
+			"Restricts results to only include media with a sound mix " +
+				"containing the text given. Multiple sound mixes will be " +
+				"combined disjunctively. e.g., {sound:dolby} matches both " +
+				"'Dolby' and 'Dolby Digital'.",
+			func(s *Searcher, v string) error {
+				s.SoundMix(v)
+				return nil
+			},
+		},
+		{
+			"color", []string{"colour"}, true,
+			"Restricts results to only include media in color " +
+				"({color:color}) or in black and white ({color:bw}).",
+			func(s *Searcher, v string) error {
+				switch strings.ToLower(v) {
+				case "color", "colour":
+					s.Color(true)
+				case "bw", "b&w", "black and white":
+					s.Color(false)
+				default:
+					return ef("Invalid color '%s' (must be 'color' or 'bw').",
+						v)
+				}
+				return nil
+			},
+		},
+		{
+			"plot", nil, true,
+			"Restricts results to only include media with a plot summary " +
+				"containing every word given. A highlighted excerpt of the " +
+				"matching text is included in the results. Note that this " +
+				"requires full-text search, which is not available with " +
+				"SQLite unless it was compiled with FTS5.",
+			func(s *Searcher, v string) error {
+				s.Fulltext("plot", v)
+				return nil
+			},
+		},
+		{
+			"quote", []string{"quotes"}, true,
+			"Restricts results to only include media with a quote " +
+				"containing every word given. e.g., {quote:i'll be back}. " +
+				"(See {plot} for details.)",
+			func(s *Searcher, v string) error {
+				s.Fulltext("quote", v)
+				return nil
+			},
+		},
+		{
+			"trivia", nil, true,
+			"Restricts results to only include media with trivia " +
+				"containing every word given. (See {plot} for details.)",
+			func(s *Searcher, v string) error {
+				s.Fulltext("trivia", v)
+				return nil
+			},
+		},
+		{
+			"goof", []string{"goofs"}, true,
+			"Restricts results to only include media with a goof " +
+				"containing every word given. (See {plot} for details.)",
+			func(s *Searcher, v string) error {
+				s.Fulltext("goof", v)
+				return nil
+			},
+		},
+		{
+			"tagline", nil, true,
+			"Restricts results to only include media with a tagline " +
+				"containing every word given. (See {plot} for details.)",
+			func(s *Searcher, v string) error {
+				s.Fulltext("tagline", v)
+				return nil
+			},
+		},
 		{
 			"credits", nil, true,
 			"A sub-search for media entities that restricts results to " +
-				"only actors media item returned from this sub-search.",
+				"only actors media item returned from this sub-search. " +
+				"This may be repeated to find actors in every media item " +
+				"returned. (See {anycredits}.)",
 			func(s *Searcher, v string) error {
 				return addSub(s, "credits", v, s.Credits)
 			},
@@ -148,11 +249,46 @@ func init() {
 		{
 			"cast", nil, true,
 			"A sub-search for cast entities that restricts results to " +
-				"only media entities in which the cast member appeared.",
+				"only media entities in which the cast member appeared. " +
+				"This may be repeated to find media entities with every " +
+				"cast member returned. (See {anycredits}.)",
 			func(s *Searcher, v string) error {
 				return addSub(s, "cast", v, s.Cast)
 			},
 		},
+		{
+			"credits*", nil, true,
+			"Like {credits:...}, except that every media item returned " +
+				"from the sub-search is used instead of a single one. " +
+				"e.g., {credits*:{genre:horror} {years:1980-1989}} finds " +
+				"actors in any horror movie from the 1980s. The sort " +
+				"criteria and limit of the sub-search are ignored.",
+			func(s *Searcher, v string) error {
+				return addSub(s, "credits*", v, s.CreditsSet)
+			},
+		},
+		{
+			"cast*", nil, true,
+			"Like {cast:...}, except that every cast member returned " +
+				"from the sub-search is used instead of a single one. " +
+				"The sort criteria and limit of the sub-search are ignored.",
+			func(s *Searcher, v string) error {
+				return addSub(s, "cast*", v, s.CastSet)
+			},
+		},
+		{
+			"anycredits", []string{"anycast"}, false,
+			"Combines multiple {cast:...} and {credits:...} sub-searches " +
+				"disjunctively, so that results need only match one of " +
+				"them. By default, results must match all of them. e.g., " +
+				"{cast:keanu reeves} {cast:carrie-anne moss} finds movies " +
+				"with both actors, but adding {anycredits} finds movies " +
+				"with either one.",
+			func(s *Searcher, v string) error {
+				s.AnyCredits()
+				return nil
+			},
+		},
 		{
 			"show", nil, true,
 			"A sub-search for TV shows that restricts results to " +
@@ -161,6 +297,35 @@ func init() {
 				return addSub(s, "show", v, s.Tvshow)
 			},
 		},
+		{
+			"show*", nil, true,
+			"Like {show:...}, except that every TV show returned from the " +
+				"sub-search is used instead of a single one. e.g., " +
+				"{show*:star trek} {episode} finds episodes of any Star " +
+				"Trek series. The sort criteria and limit of the " +
+				"sub-search are ignored.",
+			func(s *Searcher, v string) error {
+				return addSub(s, "show*", v, s.TvshowSet)
+			},
+		},
+		{
+			"not", nil, true,
+			"Removes results matching every directive in the query given. " +
+				"e.g., {genre:comedy} {not:{genre:animation}} finds " +
+				"comedies that aren't animated. A single directive can also " +
+				"be negated by prefixing it or its name with a '-', as in " +
+				"-{genre:animation} or {-genre:animation}. Only directives " +
+				"that filter results (not sorting or sub-searches) may be " +
+				"negated.",
+			func(s *Searcher, v string) error {
+				sub := s.child()
+				if err := sub.Query(v); err != nil {
+					return wrapQueryError(err, "Error with negation")
+				}
+				s.Not(sub)
+				return nil
+			},
+		},
 		{
 			"debug", nil, false,
 			"When enabled, the SQL queries used in the search will be logged " +
@@ -213,6 +378,26 @@ func init() {
 				return addRange(v, s.Votes)
 			},
 		},
+		{
+			"polarization", []string{"polar"}, true,
+			"Only show search results whose votes are polarized within the " +
+				"range specified. Polarization is the approximate " +
+				"percentage of votes with a score of either 1 or 10. " +
+				"e.g., {polarization:40-} only shows entities where at " +
+				"least 40% of votes are at the extremes.",
+			func(s *Searcher, v string) error {
+				return addRange(v, s.Polarization)
+			},
+		},
+		{
+			"runtime", []string{"minutes"}, true,
+			"Only show search results with a running time in the range of " +
+				"minutes specified. e.g., {runtime:90-120} only shows media " +
+				"that run between one and a half and two hours.",
+			func(s *Searcher, v string) error {
+				return addRange(v, s.Runtimes)
+			},
+		},
 		{
 			"billing", []string{"billed"}, true,
 			"Only show search results with credits with the billing position " +
@@ -243,6 +428,49 @@ func init() {
 				return addRange(v, s.Episodes)
 			},
 		},
+		{
+			"aired", nil, true,
+			"Only show episodes that aired in the range of dates " +
+				"specified. Dates are of the form YYYY-MM-DD, YYYY-MM or " +
+				"YYYY and ranges are separated by '..'. e.g., " +
+				"{aired:2014-01-01..2014-06-30} only shows episodes that " +
+				"aired in the first half of 2014 and {aired:2010..} only " +
+				"shows episodes that aired in 2010 or later.",
+			func(s *Searcher, v string) error {
+				min, max, err := dateRange(v)
+				if err != nil {
+					return err
+				}
+				s.Aired(min, max)
+				return nil
+			},
+		},
+		{
+			"released", nil, true,
+			"Only show media that were first released in the range of " +
+				"dates specified. Dates are written just like they are for " +
+				"{aired}. e.g., {released:2019-06-01..2019-09-01} only " +
+				"shows media that came out in the summer of 2019.",
+			func(s *Searcher, v string) error {
+				min, max, err := dateRange(v)
+				if err != nil {
+					return err
+				}
+				s.Released(min, max)
+				return nil
+			},
+		},
+		{
+			"releasecountry", []string{"rcountry"}, true,
+			"Only consider release dates in the country given when finding " +
+				"the first release of media. This affects both {released} " +
+				"and sorting by the 'released' field. e.g., " +
+				"{releasecountry:usa}.",
+			func(s *Searcher, v string) error {
+				s.ReleaseCountry(v)
+				return nil
+			},
+		},
 		{
 			"notv", nil, false,
 			"Removes 'made for TV' movies from the search results.",
@@ -274,6 +502,36 @@ func init() {
 				return nil
 			},
 		},
+		{
+			"weightvotes", nil, true,
+			"Sets the minimum number of votes used to compute the weighted " +
+				"rank of each result, which is used when sorting by the " +
+				"'weighted' field. Results with fewer votes are pulled " +
+				"toward the mean rank. The default is 25000.",
+			func(s *Searcher, v string) error {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return ef("Invalid integer '%s' for weightvotes: %s",
+						v, err)
+				}
+				s.WeightVotes(n)
+				return nil
+			},
+		},
+		{
+			"weightmean", nil, true,
+			"Sets the prior mean rank (on a scale of 0 to 100) used to " +
+				"compute the weighted rank of each result. By default, the " +
+				"mean rank of all ranked entities is used.",
+			func(s *Searcher, v string) error {
+				n, err := strconv.ParseFloat(v, 64)
+				if err != nil {
+					return ef("Invalid float '%s' for weightmean: %s", v, err)
+				}
+				s.WeightMean(n)
+				return nil
+			},
+		},
 		{
 			"limit", nil, true,
 			"Specifies a limit on the total number of search results returned.",
@@ -286,6 +544,20 @@ func init() {
 				return nil
 			},
 		},
+		{
+			"offset", nil, true,
+			"Skips the given number of search results before returning " +
+				"any. This is useful for paging through results with " +
+				"{limit:...} and a stable sort order.",
+			func(s *Searcher, v string) error {
+				n, err := strconv.Atoi(v)
+				if err != nil {
+					return ef("Invalid integer '%s' for offset: %s", v, err)
+				}
+				s.Offset(n)
+				return nil
+			},
+		},
 		{
 			"sort", nil, true,
 			"Sorts the search results according to the field given. It may " +
@@ -361,3 +633,64 @@ func intRange(s string) (*int, *int, error) {
 	}
 	return start, end, nil
 }
+
+// dateRange parses a range of dates of the form "x..y" and returns the start
+// of x and the end of y. Each date may be a day (YYYY-MM-DD), a month
+// (YYYY-MM) or a year (YYYY), so that "2014" is the same as
+// "2014-01-01..2014-12-31". Either end of the range may be omitted, in which
+// case the zero time is returned for it.
+func dateRange(s string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "..") {
+		return datePeriod(s)
+	}
+
+	pcs := strings.SplitN(s, "..", 2)
+	if p := strings.TrimSpace(pcs[0]); len(p) > 0 {
+		first, _, err := datePeriod(p)
+		if err != nil {
+			return start, end, err
+		}
+		start = first
+	}
+	if p := strings.TrimSpace(pcs[1]); len(p) > 0 {
+		_, last, err := datePeriod(p)
+		if err != nil {
+			return start, end, err
+		}
+		end = last
+	}
+	return start, end, nil
+}
+
+// datePeriod parses a single day, month or year and returns the first and
+// last days in it.
+func datePeriod(s string) (time.Time, time.Time, error) {
+	layouts := []struct {
+		layout string
+		years  int
+		months int
+	}{
+		{"2006-01-02", 0, 0},
+		{"2006-01", 0, 1},
+		{"2006", 1, 0},
+	}
+	for _, l := range layouts {
+		if len(s) != len(l.layout) {
+			continue
+		}
+		t, err := time.Parse(l.layout, s)
+		if err != nil {
+			break
+		}
+		last := t
+		if l.years > 0 || l.months > 0 {
+			last = t.AddDate(l.years, l.months, -1)
+		}
+		return t, last, nil
+	}
+	return time.Time{}, time.Time{},
+		ef("Could not parse '%s' as a date of the form YYYY-MM-DD, "+
+			"YYYY-MM or YYYY.", s)
+}
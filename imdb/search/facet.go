@@ -0,0 +1,133 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/csql"
+)
+
+// Facet is a single value of a field along with the number of search results
+// that have that value.
+type Facet struct {
+	Value string
+	Count int
+}
+
+// FacetFields is the list of fields that search results can be grouped by
+// with Facets.
+var FacetFields = []string{"entity", "genre", "decade", "mpaa", "rank"}
+
+// facetField describes how to group search results by a field. query selects
+// each value and its count from the search query, which is substituted for
+// its '%s'. format converts each value into a human readable string.
+type facetField struct {
+	query  string
+	format func(string) string
+}
+
+var facetFields = map[string]facetField{
+	"entity": {`
+		SELECT entity, COUNT(*)
+		FROM (%s) AS results
+		GROUP BY entity
+		ORDER BY COUNT(*) DESC, entity ASC
+		`, nil},
+	"genre": {`
+		SELECT g.name, COUNT(DISTINCT results.atom_id)
+		FROM (%s) AS results
+		INNER JOIN genre AS g ON g.atom_id = results.atom_id
+		GROUP BY g.name
+		ORDER BY COUNT(DISTINCT results.atom_id) DESC, g.name ASC
+		`, nil},
+	"decade": {`
+		SELECT (year / 10) * 10 AS decade, COUNT(*)
+		FROM (%s) AS results
+		WHERE year > 0
+		GROUP BY decade
+		ORDER BY decade ASC
+		`, func(v string) string { return v + "s" }},
+	"mpaa": {`
+		SELECT mp.rating, COUNT(DISTINCT results.atom_id)
+		FROM (%s) AS results
+		INNER JOIN mpaa_rating AS mp ON mp.atom_id = results.atom_id
+		GROUP BY mp.rating
+		ORDER BY mp.rating ASC
+		`, nil},
+	"rank": {`
+		SELECT
+			CASE WHEN rank >= 90 THEN 90 ELSE (rank / 10) * 10 END AS bucket,
+			COUNT(*)
+		FROM (%s) AS results
+		WHERE votes > 0
+		GROUP BY bucket
+		ORDER BY bucket DESC
+		`, rankBucket},
+}
+
+// rankBucket formats the lower bound of a range of ranks. The last range
+// includes perfect ranks.
+func rankBucket(v string) string {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return v
+	}
+	if n >= 90 {
+		return sf("%d-100", n)
+	}
+	return sf("%d-%d", n, n+9)
+}
+
+// Count returns the total number of results of the search, regardless of its
+// limit or offset.
+func (s *Searcher) Count() (n int, err error) {
+	defer csql.Safe(&err)
+
+	if err := s.prepare(); err != nil {
+		return 0, err
+	}
+	q := sf("SELECT COUNT(*) FROM (%s) AS results", s.wrappedSQL())
+	return csql.Count(s.db, q, s.args...), nil
+}
+
+// Facets groups all of the results of the search (regardless of its limit or
+// offset) by each of the fields given, and returns the number of results
+// with each value of each field. If no fields are given, then the results
+// are grouped by every field in FacetFields.
+//
+// Decades and ranks are grouped in ranges of ten, e.g., "1990s" or "80-89".
+// Results that don't have a value for a field are not counted in its facets.
+// Results with more than one value for a field (like genres) are counted once
+// for each value.
+func (s *Searcher) Facets(fields ...string) (fs map[string][]Facet, err error) {
+	defer csql.Safe(&err)
+
+	if len(fields) == 0 {
+		fields = FacetFields
+	}
+	for _, field := range fields {
+		if _, ok := facetFields[field]; !ok {
+			return nil, ef("Unknown facet field '%s'. Available fields: %s",
+				field, strings.Join(FacetFields, ", "))
+		}
+	}
+	if err := s.prepare(); err != nil {
+		return nil, err
+	}
+
+	fs = make(map[string][]Facet, len(fields))
+	for _, field := range fields {
+		ff := facetFields[field]
+		q := sf(ff.query, s.wrappedSQL())
+		rows := csql.Query(s.db, q, s.args...)
+		csql.ForRow(rows, func(scanner csql.RowScanner) {
+			var f Facet
+			csql.Scan(scanner, &f.Value, &f.Count)
+			if ff.format != nil {
+				f.Value = ff.format(f.Value)
+			}
+			fs[field] = append(fs[field], f)
+		})
+	}
+	return fs, nil
+}
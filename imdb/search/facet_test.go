@@ -0,0 +1,51 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteCount(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	s, err := Query(db, "%matrix% {limit:1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, offset := range []int{0, 2, 5} {
+		n, err := s.Offset(offset).Count()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 3 {
+			t.Errorf("Expected 3 results with offset %d but got %d.",
+				offset, n)
+		}
+	}
+}
+
+func TestSQLiteFacets(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	s, err := Query(db, "%matrix% {limit:1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs, err := s.Offset(1).Facets("genre", "decade")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]Facet{
+		"genre":  {{"action", 2}, {"sci-fi", 2}, {"drama", 1}},
+		"decade": {{"1990s", 1}, {"2000s", 2}},
+	}
+	if !reflect.DeepEqual(fs, want) {
+		t.Errorf("Expected facets %v but got %v.", want, fs)
+	}
+
+	if _, err := s.Facets("color"); err == nil {
+		t.Errorf("Expected an error for an unknown facet field.")
+	}
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Macros is a set of named search queries (or saved searches) that can be
+// used inside other search queries. A macro is referenced with a directive of
+// the form {@NAME[:ARGUMENTS]}, which is replaced by the query named before
+// the search query is parsed. Macros may reference other macros.
+//
+// The arguments are whitespace delimited. In the query of a macro, '$1' is
+// replaced by the first argument, '$2' by the second and so on, while '$*' is
+// replaced by all of the arguments. A literal '$' can be written as '$$'.
+// For example, with the macro 'decade' defined as '{years:$1-$2} {sort:rank
+// desc}', the query '{@decade:1990 1999}' is the same as '{years:1990-1999}
+// {sort:rank desc}'.
+//
+// Note that a macro is replaced verbatim. If the query of a macro contains
+// alternatives separated by '|', then it should be surrounded by parentheses.
+type Macros map[string]string
+
+// Expand returns the query given with every macro directive replaced by the
+// query it names. An error is returned if a macro doesn't exist, is missing
+// arguments or references itself (possibly through other macros).
+//
+// Macro directives inside quoted strings are not replaced.
+func (ms Macros) Expand(query string) (string, error) {
+	return ms.expand(query, nil)
+}
+
+// expand replaces macro directives in query, where stack is the list of
+// macros currently being expanded. Errors are reported at the column of the
+// macro directive in query.
+func (ms Macros) expand(query string, stack []string) (string, error) {
+	if !strings.ContainsRune(query, '@') {
+		return query, nil
+	}
+	rs := []rune(query)
+	buf := make([]rune, 0, len(rs))
+	quoted := false
+	for i := 0; i < len(rs); i++ {
+		switch {
+		case rs[i] == '\\':
+			buf = append(buf, rs[i])
+			if i+1 < len(rs) {
+				i++
+				buf = append(buf, rs[i])
+			}
+		case rs[i] == '"':
+			quoted = !quoted
+			buf = append(buf, rs[i])
+		case !quoted && rs[i] == '{' && isMacro(rs[i+1:]):
+			end, err := matchBrace(rs, i, 0)
+			if err != nil {
+				return "", err
+			}
+			body, err := ms.macro(string(rs[i+1:end]), stack)
+			if err != nil {
+				if qe, ok := err.(*QueryError); ok {
+					err = qe.Err
+				}
+				return "", &QueryError{i + 1, err}
+			}
+			buf = append(buf, []rune(body)...)
+			i = end
+		default:
+			buf = append(buf, rs[i])
+		}
+	}
+	return string(buf), nil
+}
+
+// isMacro returns true if rs (following a '{') starts a macro directive.
+func isMacro(rs []rune) bool {
+	s := strings.TrimLeftFunc(string(rs), unicode.IsSpace)
+	return strings.HasPrefix(s, "@")
+}
+
+// macro returns the fully expanded query of the macro directive given, which
+// excludes its braces.
+func (ms Macros) macro(directive string, stack []string) (string, error) {
+	name, arg := directive, ""
+	if sep := strings.IndexRune(directive, ':'); sep > -1 {
+		name, arg = directive[:sep], directive[sep+1:]
+	}
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	arg = strings.TrimSpace(arg)
+
+	query, ok := ms[name]
+	if !ok {
+		return "", ef("Unknown saved search '@%s'.", name)
+	}
+	for i, other := range stack {
+		if other == name {
+			return "", cycleError(append(stack[i:], name))
+		}
+	}
+	query, err := substitute(query, arg)
+	if err != nil {
+		return "", ef("Saved search '@%s': %s", name, err)
+	}
+	stack = append(stack[:len(stack):len(stack)], name)
+	query, err = ms.expand(query, stack)
+	if err != nil {
+		// Cycles are reported without the context of every macro in them,
+		// since the cycle itself lists them.
+		if qe, ok := err.(*QueryError); ok {
+			if _, ok := qe.Err.(cycleError); ok {
+				return "", qe.Err
+			}
+		}
+		return "", wrapQueryError(err, "In saved search '@%s'", name)
+	}
+	return query, nil
+}
+
+// cycleError is returned when macros reference each other in a cycle. It
+// lists every macro in the cycle.
+type cycleError []string
+
+func (e cycleError) Error() string {
+	return sf("Saved searches reference each other in a cycle: @%s",
+		strings.Join(e, " -> @"))
+}
+
+// substitute replaces the argument placeholders in the query of a macro with
+// the arguments given.
+func substitute(query, arg string) (string, error) {
+	args := strings.Fields(arg)
+	rs := []rune(query)
+	buf := make([]rune, 0, len(rs))
+	for i := 0; i < len(rs); i++ {
+		if rs[i] != '$' || i+1 == len(rs) {
+			buf = append(buf, rs[i])
+			continue
+		}
+		switch next := rs[i+1]; {
+		case next == '$':
+			buf = append(buf, '$')
+			i++
+		case next == '*':
+			buf = append(buf, []rune(arg)...)
+			i++
+		case next >= '1' && next <= '9':
+			j := i + 1
+			for j < len(rs) && rs[j] >= '0' && rs[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(string(rs[i+1 : j]))
+			if n > len(args) {
+				return "", ef("Argument $%d is required, but only %d "+
+					"were given.", n, len(args))
+			}
+			buf = append(buf, []rune(args[n-1])...)
+			i = j - 1
+		default:
+			buf = append(buf, rs[i])
+		}
+	}
+	return string(buf), nil
+}
@@ -0,0 +1,45 @@
+package search
+
+import "testing"
+
+func TestMacros(t *testing.T) {
+	ms := Macros{
+		"good":   "{votes:10000-} {notv}",
+		"decade": "{movie} {@good} {years:$1-$2}",
+		"all":    "$* $$1",
+		"a":      "x {@b}",
+		"b":      "{@a}",
+	}
+	tests := []struct {
+		query, expanded string
+		ok              bool
+	}{
+		{"{@good}", "{votes:10000-} {notv}", true},
+		{"x { @good }", "x {votes:10000-} {notv}", true},
+		{"{@decade:1990 1999}",
+			"{movie} {votes:10000-} {notv} {years:1990-1999}", true},
+		{"{show:{@good} simpsons}", "{show:{votes:10000-} {notv} simpsons}",
+			true},
+		{"{@all:a b}", "a b $1", true},
+		{`"{@good}" \{@good}`, `"{@good}" \{@good}`, true},
+		{"{@decade:1990}", "", false},
+		{"{@nope}", "", false},
+		{"{@a}", "", false},
+	}
+	for _, test := range tests {
+		got, err := ms.Expand(test.query)
+		if !test.ok {
+			if err == nil {
+				t.Errorf("Expected an error expanding '%s' but got '%s'.",
+					test.query, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Could not expand '%s': %s", test.query, err)
+		} else if got != test.expanded {
+			t.Errorf("Expected '%s' to expand to '%s' but got '%s'.",
+				test.query, test.expanded, got)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/csql"
+)
+
+// resultColumns are the columns of the search query that are scanned into
+// each result, in order. (See scanResult.)
+var resultColumns = []string{
+	"entity", "atom_id", "name", "year", "similarity", "attrs",
+	"votes", "rank", "distribution", "weighted", "released", "snippet",
+	"c_actor_id", "c_media_id", "c_character", "c_position", "c_attrs",
+}
+
+// pageKey is a sort key used to page through results. column is one of the
+// sort fields accepted by Sort.
+type pageKey struct {
+	column string
+	desc   bool
+}
+
+// Page returns the page of results that follows the position given by
+// cursor, along with the cursor of the next page. An empty cursor returns
+// the first page, and an empty cursor is returned after the last page.
+//
+// The size of each page is the limit of the search. (See Limit.) Results are
+// sorted as they would be by Results, except that ties are always broken by
+// the atom identifier and the credit of each result, and missing values are
+// sorted last.
+// Unlike Offset, pages are found with the values of the sort fields of the
+// last result on the previous page (i.e., "keyset pagination"). This means
+// that getting any page is fast and that results aren't skipped or repeated
+// when the database changes between pages.
+//
+// A cursor is only valid for a search with the same sort criteria as the one
+// that returned it. Any offset set on the search is ignored.
+func (s *Searcher) Page(cursor string) (rs []Result, next string, err error) {
+	defer csql.Safe(&err)
+
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	// The keys depend on whether sub-searches join credits, which is only
+	// known once they've been run.
+	if err := s.prepare(); err != nil {
+		return nil, "", err
+	}
+	keys := s.pageKeys()
+	if after != nil && len(after) != len(keys) {
+		return nil, "", ef("Cursor does not match the sort criteria of " +
+			"the search.")
+	}
+
+	s.keys = keys
+	defer func() { s.keys = nil }()
+	q := s.pageSQL(after)
+
+	var last []interface{}
+	rows := csql.Query(s.db, q, s.args...)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		vals := make([]interface{}, len(keys))
+		dests := make([]interface{}, len(keys))
+		for i := range vals {
+			dests[i] = &vals[i]
+		}
+		rs = append(rs, scanResult(scanner, dests...))
+		last = vals
+	})
+	if s.limit > 0 && len(rs) == s.limit {
+		next = encodeCursor(last)
+	}
+	return
+}
+
+// creditKeys are the columns that break ties between results for the same
+// atom. There is more than one such result when a search joins the credits of
+// a sub-search, since an actor may have more than one credit in the same
+// media (e.g., playing two characters).
+var creditKeys = []string{"c_character", "c_position", "c_attrs"}
+
+// pageKeys returns the sort keys of the search, which always end with the
+// atom identifier (and the credit of each result, if credits are joined) so
+// that the order of results is total.
+func (s *Searcher) pageKeys() []pageKey {
+	var keys []pageKey
+	if s.fuzzy && len(s.name) > 0 {
+		keys = append(keys, pageKey{"similarity", true})
+	}
+	for _, ord := range s.order {
+		if len(orderColumnQualified(ord.column)) == 0 {
+			continue
+		}
+		desc := strings.EqualFold(ord.order, "desc")
+		keys = append(keys, pageKey{ord.column, desc})
+	}
+	keys = append(keys, pageKey{"atom_id", false})
+	if len(s.creditAliases()) > 0 {
+		for _, col := range creditKeys {
+			keys = append(keys, pageKey{col, false})
+		}
+	}
+	return keys
+}
+
+// isColumn returns true if the sort key is a column of the search query,
+// rather than an expression that must be added to it. (The earliest release
+// date is a column since it is computed whenever the results are sorted by
+// it.)
+func (k pageKey) isColumn() bool {
+	if k.column == "released" {
+		return true
+	}
+	for _, col := range creditKeys {
+		if k.column == col {
+			return true
+		}
+	}
+	return orderColumnQualified(k.column) == k.column
+}
+
+// keyRef returns the column of the i'th sort key in the query wrapping the
+// search query. Keys that are already columns of the search query are
+// referenced directly.
+func (s *Searcher) keyRef(i int) string {
+	if s.keys[i].isColumn() {
+		return "page." + s.keys[i].column
+	}
+	return sf("page.page_key_%d", i)
+}
+
+// pageColumns returns the columns added to the search query for sort keys
+// that aren't already columns of it.
+func (s *Searcher) pageColumns() string {
+	var cols []string
+	for i, k := range s.keys {
+		if !k.isColumn() {
+			cols = append(cols, sf(", %s AS page_key_%d",
+				orderColumnQualified(k.column), i))
+		}
+	}
+	return strings.Join(cols, "\n")
+}
+
+// pageSQL returns the query for a page of results following the values of
+// the sort keys given. If after is nil, then the first page is returned.
+func (s *Searcher) pageSQL(after []interface{}) string {
+	inner := s.wrappedSQL()
+
+	var cols, order []string
+	for _, col := range resultColumns {
+		cols = append(cols, "page."+col)
+	}
+	for i, k := range s.keys {
+		ref := s.keyRef(i)
+		cols = append(cols, ref)
+		dir := "ASC"
+		if k.desc {
+			dir = "DESC"
+		}
+		order = append(order, sf("(%s IS NULL), %s %s", ref, ref, dir))
+	}
+	limit := ""
+	if s.limit >= 0 {
+		limit = sf("LIMIT %d", s.limit)
+	}
+	q := sf(`
+		SELECT %s
+		FROM (%s) AS page
+		WHERE %s
+		ORDER BY %s
+		%s
+		`,
+		strings.Join(cols, ", "), inner, s.keysetCond(after),
+		strings.Join(order, ", "), limit)
+	if s.debug {
+		pef("%s\n", q)
+	}
+	return q
+}
+
+// keysetCond returns a condition that is satisfied by every result that
+// comes after the values of the sort keys given.
+//
+// A result comes after the values if, for some key, the result's value is
+// greater (or smaller, for descending keys) and the values of all previous
+// keys are equal. Since missing values are sorted last, a missing value is
+// never followed by a value for the same key, but a value is always followed
+// by a missing one.
+func (s *Searcher) keysetCond(after []interface{}) string {
+	if after == nil {
+		return "1 = 1"
+	}
+	var disj, eqs []string
+	for i, k := range s.keys {
+		ref, v := s.keyRef(i), after[i]
+		if v == nil {
+			eqs = append(eqs, sf("%s IS NULL", ref))
+			continue
+		}
+		op := ">"
+		if k.desc {
+			op = "<"
+		}
+		cmp := sf("(%s IS NULL OR %s %s %s)", ref, ref, op, s.bindKey(v))
+		disj = append(disj,
+			sf("(%s)", strings.Join(append(eqs, cmp), " AND ")))
+		// Every parameter must be used, so the equality of the last key
+		// (which would never be used) isn't added.
+		if i < len(s.keys)-1 {
+			eqs = append(eqs, sf("%s = %s", ref, s.bindKey(v)))
+		}
+	}
+	if len(disj) == 0 {
+		return "1 = 0"
+	}
+	return sf("(%s)", strings.Join(disj, " OR "))
+}
+
+// bindKey binds the value of a sort key. With PostgreSQL, floating point
+// values are bound as text, so that they are converted to the type of the
+// key. (e.g., similarity scores are single precision, and their values would
+// not be equal after being converted to double precision.)
+func (s *Searcher) bindKey(v interface{}) string {
+	if f, ok := v.(float64); ok && s.db.Driver == "postgres" {
+		return s.bind(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	return s.bind(v)
+}
+
+// cursorValue is the value of a single sort key in a cursor. Its type is
+// kept so that it can be bound as a query parameter with the same type.
+type cursorValue struct {
+	Type  string `json:"t"`
+	Value string `json:"v"`
+}
+
+// encodeCursor returns a cursor containing the values of the sort keys of a
+// result.
+func encodeCursor(vals []interface{}) string {
+	cvals := make([]cursorValue, len(vals))
+	for i, v := range vals {
+		switch v := v.(type) {
+		case nil:
+			cvals[i] = cursorValue{"null", ""}
+		case bool:
+			cvals[i] = cursorValue{"bool", strconv.FormatBool(v)}
+		case int64:
+			cvals[i] = cursorValue{"int", strconv.FormatInt(v, 10)}
+		case float64:
+			cvals[i] = cursorValue{"float",
+				strconv.FormatFloat(v, 'g', -1, 64)}
+		case time.Time:
+			cvals[i] = cursorValue{"time", v.Format(time.RFC3339Nano)}
+		case []byte:
+			cvals[i] = cursorValue{"string", string(v)}
+		default:
+			cvals[i] = cursorValue{"string", sf("%v", v)}
+		}
+	}
+	bs, err := json.Marshal(cvals)
+	if err != nil {
+		// Marshaling a slice of structs with strings cannot fail.
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+// decodeCursor returns the values of the sort keys in a cursor. If the cursor
+// is empty, then nil is returned.
+func decodeCursor(cursor string) ([]interface{}, error) {
+	if len(cursor) == 0 {
+		return nil, nil
+	}
+	invalid := ef("Invalid cursor '%s'.", cursor)
+	bs, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, invalid
+	}
+	var cvals []cursorValue
+	if err := json.Unmarshal(bs, &cvals); err != nil {
+		return nil, invalid
+	}
+	vals := make([]interface{}, len(cvals))
+	for i, cv := range cvals {
+		var err error
+		switch cv.Type {
+		case "null":
+			vals[i] = nil
+		case "bool":
+			vals[i], err = strconv.ParseBool(cv.Value)
+		case "int":
+			vals[i], err = strconv.ParseInt(cv.Value, 10, 64)
+		case "float":
+			vals[i], err = strconv.ParseFloat(cv.Value, 64)
+		case "time":
+			vals[i], err = time.Parse(time.RFC3339Nano, cv.Value)
+		case "string":
+			vals[i] = cv.Value
+		default:
+			err = invalid
+		}
+		if err != nil {
+			return nil, invalid
+		}
+	}
+	return vals, nil
+}
@@ -0,0 +1,144 @@
+package search
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+func TestCursors(t *testing.T) {
+	released := time.Date(1999, 3, 31, 0, 0, 0, 0, time.UTC)
+	vals := []interface{}{
+		nil, true, int64(42), 0.75, released, "Neo", []byte("Trinity"),
+	}
+	got, err := decodeCursor(encodeCursor(vals))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Byte slices are decoded as strings.
+	want := []interface{}{
+		nil, true, int64(42), 0.75, released, "Neo", "Trinity",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected cursor values %#v but got %#v.", want, got)
+	}
+
+	if vals, err := decodeCursor(""); vals != nil || err != nil {
+		t.Errorf("Expected no values for an empty cursor, but got %v (%v).",
+			vals, err)
+	}
+}
+
+func TestCursorErrors(t *testing.T) {
+	enc := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+	cursors := []string{
+		"not a cursor!",
+		enc("not json"),
+		enc(`{"t": "int", "v": "1"}`),
+		enc(`[{"t": "int", "v": "one"}]`),
+		enc(`[{"t": "time", "v": "1999-03-31"}]`),
+		enc(`[{"t": "atom", "v": "1"}]`),
+	}
+	for _, cursor := range cursors {
+		if _, err := decodeCursor(cursor); err == nil {
+			t.Errorf("Expected an error for cursor '%s'.", cursor)
+		}
+	}
+
+	s := New(testDB()).Sort("year", "desc")
+	cursor := encodeCursor([]interface{}{int64(1999)})
+	if _, _, err := s.Page(cursor); err == nil {
+		t.Errorf("Expected an error for a cursor with too few sort keys.")
+	}
+}
+
+func TestKeysetCond(t *testing.T) {
+	tests := []struct {
+		after []interface{}
+		cond  string
+		args  []interface{}
+	}{
+		{nil, "1 = 1", nil},
+		{
+			[]interface{}{int64(80), int64(7)},
+			"(((page.page_key_0 IS NULL OR page.page_key_0 < $1)) OR " +
+				"(page.page_key_0 = $2 AND " +
+				"(page.atom_id IS NULL OR page.atom_id > $3)))",
+			[]interface{}{int64(80), int64(80), int64(7)},
+		},
+		{
+			[]interface{}{nil, int64(7)},
+			"((page.page_key_0 IS NULL AND " +
+				"(page.atom_id IS NULL OR page.atom_id > $1)))",
+			[]interface{}{int64(7)},
+		},
+		{[]interface{}{nil, nil}, "1 = 0", nil},
+	}
+	for _, test := range tests {
+		s := New(testDB())
+		s.keys = []pageKey{{"rank", true}, {"atom_id", false}}
+		if cond := s.keysetCond(test.after); cond != test.cond {
+			t.Errorf("Expected the condition after %v to be\n%s\nbut got\n%s",
+				test.after, test.cond, cond)
+		}
+		if !reflect.DeepEqual(s.args, test.args) {
+			t.Errorf("Expected the parameters after %v to be %v but got %v",
+				test.after, test.args, s.args)
+		}
+	}
+}
+
+func TestSQLitePages(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	type result struct {
+		id        imdb.Atom
+		character string
+	}
+	tests := []struct {
+		query   string
+		results []result
+	}{
+		{"%matrix% {sort:year desc} {limit:2}",
+			[]result{{2, ""}, {3, ""}, {1, ""}}},
+		// Missing release dates are sorted last.
+		{"%matrix% {sort:released desc} {limit:1}",
+			[]result{{3, ""}, {1, ""}, {2, ""}}},
+		// Both credits in the same movie are on different pages.
+		{"{cast:%keanu%} {limit:1}",
+			[]result{{1, "Neo"}, {1, "Thomas Anderson"}, {3, "Neo"}}},
+	}
+	for _, test := range tests {
+		s, err := Query(db, test.query)
+		if err != nil {
+			t.Errorf("Could not parse '%s': %s", test.query, err)
+			continue
+		}
+		var got []result
+		cursor := ""
+		for pages := 0; pages <= len(test.results); pages++ {
+			rs, next, err := s.Page(cursor)
+			if err != nil {
+				t.Errorf("Could not page '%s': %s", test.query, err)
+				break
+			}
+			for _, r := range rs {
+				got = append(got, result{r.Id, r.Credit.Character})
+			}
+			if len(next) == 0 {
+				break
+			}
+			cursor = next
+		}
+		if !reflect.DeepEqual(got, test.results) {
+			t.Errorf("Expected pages of '%s' to have %v but got %v.",
+				test.query, test.results, got)
+		}
+	}
+}
@@ -0,0 +1,673 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// QueryError is an error in a search query string along with the position in
+// the query where it occurred. Columns start at 1 and count characters (not
+// bytes). Columns of errors in sub-searches are relative to the entire query.
+type QueryError struct {
+	Column int
+	Err    error
+}
+
+func (e *QueryError) Error() string {
+	return sf("column %d: %s", e.Column, e.Err)
+}
+
+// queryErrorf returns a *QueryError at the column given.
+func queryErrorf(col int, format string, v ...interface{}) error {
+	return &QueryError{col, ef(format, v...)}
+}
+
+// wrapQueryError adds context to the message of an error. If the error is a
+// *QueryError, its position is preserved.
+func wrapQueryError(err error, format string, v ...interface{}) error {
+	if qe, ok := err.(*QueryError); ok {
+		return &QueryError{qe.Column, ef("%s: %s", sf(format, v...), qe.Err)}
+	}
+	return ef("%s: %s", sf(format, v...), err)
+}
+
+// queryArgs is the set of commands whose argument is itself a search query.
+// Their arguments are parsed along with the rest of the query, so that errors
+// in them are reported with the right position.
+var queryArgs = map[string]bool{
+	"credits": true, "cast": true, "show": true, "not": true,
+	"credits*": true, "cast*": true, "show*": true,
+}
+
+// The kinds of tokens in a search query.
+const (
+	tokText      = iota // plain text, possibly quoted
+	tokDirective        // {name[:argument]}
+	tokLParen           // (
+	tokRParen           // )
+	tokPipe             // |
+)
+
+// token is a single lexeme of a search query. Only text and directive tokens
+// have values. The value of a text token has its quotes and escapes removed,
+// while the value of a directive token is everything between its braces.
+type token struct {
+	kind  int
+	col   int
+	val   string
+	argAt int // the offset of the directive's argument in val, if any
+}
+
+// lexQuery breaks a search query into tokens. Text is whitespace delimited,
+// unless it is surrounded by double quotes. Any character preceded by a
+// backslash is treated as text. Directives are delimited by (possibly nested)
+// curly braces, and may be preceded by a '-'.
+//
+// The column of each token is offset by base, which is used for the arguments
+// of directives that are search queries themselves.
+func lexQuery(query string, base int) ([]token, error) {
+	var tokens []token
+	rs := []rune(query)
+	for i := 0; i < len(rs); {
+		col := base + i + 1
+		switch r := rs[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokLParen, col: col})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokRParen, col: col})
+			i++
+		case r == '|':
+			tokens = append(tokens, token{kind: tokPipe, col: col})
+			i++
+		case r == '}':
+			return nil, queryErrorf(col, "Unexpected '}'.")
+		case r == '"':
+			text, n, err := lexQuoted(rs[i:], col)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokText, col: col, val: text})
+			i += n
+		case r == '{' || (r == '-' && i+1 < len(rs) && rs[i+1] == '{'):
+			// A '-' before a directive negates it, just like a '-' before
+			// its name. e.g., -{genre:horror} is {-genre:horror}.
+			open := i
+			if r == '-' {
+				open++
+			}
+			end, err := matchBrace(rs, open, base)
+			if err != nil {
+				return nil, err
+			}
+			val := string(rs[open+1 : end])
+			if r == '-' {
+				val = "-" + val
+			}
+			argAt := -1
+			if sep := strings.IndexRune(val, ':'); sep > -1 {
+				argAt = len([]rune(val[:sep])) + 1
+			}
+			tokens = append(tokens, token{tokDirective, col, val, argAt})
+			i = end + 1
+		default:
+			var buf []rune
+			for ; i < len(rs) && !isSpecial(rs[i]); i++ {
+				if rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				buf = append(buf, rs[i])
+			}
+			tokens = append(tokens, token{kind: tokText, col: col,
+				val: string(buf)})
+		}
+	}
+	return tokens, nil
+}
+
+// isSpecial returns true if the character ends plain text that isn't quoted.
+func isSpecial(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`(){}|"`, r)
+}
+
+// lexQuoted reads a double quoted string at the beginning of rs and returns
+// its contents along with the number of characters read.
+func lexQuoted(rs []rune, col int) (string, int, error) {
+	var buf []rune
+	for i := 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+			}
+			buf = append(buf, rs[i])
+		case '"':
+			return string(buf), i + 1, nil
+		default:
+			buf = append(buf, rs[i])
+		}
+	}
+	return "", 0, queryErrorf(col, "Unterminated quoted string.")
+}
+
+// matchBrace returns the index of the '}' that closes the '{' at rs[open].
+// Braces inside quoted strings or preceded by a backslash are ignored.
+func matchBrace(rs []rune, open, base int) (int, error) {
+	depth, quoted := 0, false
+	for i := open; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case '{':
+			if !quoted {
+				depth++
+			}
+		case '}':
+			if !quoted {
+				depth--
+				if depth == 0 {
+					return i, nil
+				}
+			}
+		}
+	}
+	return 0, queryErrorf(base+open+1, "Unterminated directive (missing '}').")
+}
+
+// The following types make up the syntax tree of a search query.
+
+// queryGroup is a parenthesized part of a search query. (The entire query is
+// also a group.) Each branch of a group is a list of nodes that are combined
+// conjunctively, while the branches themselves are separated by '|' and
+// combined disjunctively.
+type queryGroup struct {
+	col      int
+	branches [][]queryNode
+}
+
+// queryNode is a single element of a branch in a group. It is exactly one of
+// text, a directive or a nested group.
+type queryNode struct {
+	text      *queryText
+	directive *queryDirective
+	group     *queryGroup
+}
+
+// queryText is text to search the names of entities with.
+type queryText struct {
+	col  int
+	text string
+}
+
+// queryDirective is a directive along with its argument.
+type queryDirective struct {
+	col     int
+	name    string
+	negated bool
+	cmd     command
+	arg     string
+	argCol  int
+}
+
+// parseQuery parses an entire search query into a syntax tree.
+func parseQuery(query string, base int) (*queryGroup, error) {
+	tokens, err := lexQuery(query, base)
+	if err != nil {
+		return nil, err
+	}
+	g, rest, err := parseBranches(tokens, base+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, queryErrorf(rest[0].col,
+			"Unbalanced parentheses: unexpected ')'.")
+	}
+	return g, nil
+}
+
+// parseBranches parses tokens up to the end of the current group, and returns
+// the group along with the tokens following it (starting with the ')' that
+// ended it, if there is one).
+func parseBranches(tokens []token, col int) (*queryGroup, []token, error) {
+	g := &queryGroup{col: col, branches: [][]queryNode{nil}}
+	for len(tokens) > 0 {
+		tok := tokens[0]
+		tokens = tokens[1:]
+
+		var node queryNode
+		switch tok.kind {
+		case tokRParen:
+			if err := g.checkBranches(tok.col); err != nil {
+				return nil, nil, err
+			}
+			return g, append([]token{tok}, tokens...), nil
+		case tokPipe:
+			if len(g.branches[len(g.branches)-1]) == 0 {
+				return nil, nil, queryErrorf(tok.col,
+					"Every alternative separated by '|' must be non-empty.")
+			}
+			g.branches = append(g.branches, nil)
+			continue
+		case tokText:
+			node.text = &queryText{tok.col, tok.val}
+		case tokDirective:
+			d, err := parseDirective(tok)
+			if err != nil {
+				return nil, nil, err
+			}
+			node.directive = d
+		case tokLParen:
+			sub, rest, err := parseBranches(tokens, tok.col)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 {
+				return nil, nil, queryErrorf(tok.col,
+					"Unbalanced parentheses: missing ')'.")
+			}
+			node.group = sub
+			tokens = rest[1:]
+		}
+		last := len(g.branches) - 1
+		g.branches[last] = append(g.branches[last], node)
+	}
+	if err := g.checkBranches(g.col); err != nil {
+		return nil, nil, err
+	}
+	return g, tokens, nil
+}
+
+// checkBranches returns an error at the column given if the last branch of
+// the group is empty after a '|'.
+func (g *queryGroup) checkBranches(col int) error {
+	if len(g.branches) > 1 && len(g.branches[len(g.branches)-1]) == 0 {
+		return queryErrorf(col,
+			"Every alternative separated by '|' must be non-empty.")
+	}
+	return nil
+}
+
+// parseDirective parses the contents of a directive token. The directive
+// must exist and must have an argument if and only if it requires one.
+func parseDirective(tok token) (*queryDirective, error) {
+	d := &queryDirective{col: tok.col}
+	rs := []rune(tok.val)
+	name := tok.val
+	if tok.argAt > -1 {
+		name = string(rs[:tok.argAt-1])
+		arg := rs[tok.argAt:]
+		skip := len(arg) - len([]rune(strings.TrimLeftFunc(
+			string(arg), unicode.IsSpace)))
+		d.arg = strings.TrimSpace(string(arg))
+		d.argCol = tok.col + 1 + tok.argAt + skip
+	}
+	d.name = strings.TrimSpace(name)
+	if strings.HasPrefix(d.name, "-") {
+		d.name, d.negated = d.name[1:], true
+	}
+	if len(d.name) == 0 {
+		return nil, queryErrorf(d.col, "Directives must have a name.")
+	}
+
+	cmd, ok := allCommands[d.name]
+	if !ok {
+		return nil, queryErrorf(d.col, "Unrecognized search option: %s",
+			d.name)
+	}
+	if cmd.hasArg && len(d.arg) == 0 {
+		return nil, queryErrorf(d.col,
+			"The %s command requires an argument.", d.name)
+	} else if !cmd.hasArg && len(d.arg) > 0 {
+		return nil, queryErrorf(d.col,
+			"The %s command does not have an argument.", d.name)
+	}
+	if d.negated && unfilterable[cmd.name] {
+		return nil, queryErrorf(d.col,
+			"The %s command cannot be negated or used in a group.", d.name)
+	}
+	d.cmd = cmd
+
+	if queryArgs[cmd.name] {
+		// The argument is parsed again when the directive is applied, but
+		// parsing it here reports syntax errors before anything else.
+		if _, err := parseQuery(d.arg, d.argCol-1); err != nil {
+			return nil, err
+		}
+	} else if unq, ok := unquote(d.arg); ok {
+		d.arg = unq
+	}
+	return d, nil
+}
+
+// unquote returns the contents of s if it is a single double quoted string.
+func unquote(s string) (string, bool) {
+	if !strings.HasPrefix(s, `"`) {
+		return "", false
+	}
+	rs := []rune(s)
+	text, n, err := lexQuoted(rs, 0)
+	if err != nil || n != len(rs) {
+		return "", false
+	}
+	return text, true
+}
+
+// apply adds the directives and text in the group to the searcher given.
+// A group with a single branch is applied directly to the searcher, while
+// each branch of a group with more than one is applied to a new searcher in
+// an Or group.
+func (g *queryGroup) apply(s *Searcher) error {
+	if len(g.branches) == 1 {
+		return applyBranch(s, g.branches[0])
+	}
+	var subs []*Searcher
+	for _, branch := range g.branches {
+		sub := s.child()
+		if err := applyBranch(sub, branch); err != nil {
+			return err
+		}
+		subs = append(subs, sub)
+	}
+	s.Or(subs...)
+	return nil
+}
+
+func applyBranch(s *Searcher, branch []queryNode) error {
+	for _, node := range branch {
+		var err error
+		switch {
+		case node.text != nil:
+			s.Text(node.text.text)
+		case node.directive != nil:
+			err = node.directive.apply(s)
+		case node.group.isText():
+			// Parentheses around plain text are part of the text. e.g.,
+			// "(500) days of summer".
+			s.Text(node.group.String())
+		default:
+			err = node.group.apply(s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply adds the directive to the searcher given. Errors are reported at the
+// position of the directive, unless they come from a sub-search, in which
+// case they are reported at their position in the sub-search.
+func (d *queryDirective) apply(s *Searcher) error {
+	if s.parent != nil && unfilterable[d.cmd.name] {
+		return queryErrorf(d.col,
+			"The %s command cannot be negated or used in a group.", d.name)
+	}
+	target := s
+	if d.negated {
+		target = s.child()
+	}
+	if err := d.cmd.add(target, d.arg); err != nil {
+		if qe, ok := err.(*QueryError); ok {
+			return &QueryError{d.argCol - 1 + qe.Column, qe.Err}
+		}
+		return &QueryError{d.col, err}
+	}
+	if d.negated {
+		s.Not(target)
+	}
+	return nil
+}
+
+// isText returns true if and only if the group consists of a single branch of
+// plain text.
+func (g *queryGroup) isText() bool {
+	if len(g.branches) != 1 || len(g.branches[0]) == 0 {
+		return false
+	}
+	for _, node := range g.branches[0] {
+		if node.text == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the text of a group of plain text, including its
+// parentheses.
+func (g *queryGroup) String() string {
+	var texts []string
+	for _, node := range g.branches[0] {
+		texts = append(texts, node.text.text)
+	}
+	return "(" + strings.Join(texts, " ") + ")"
+}
+
+// Canonical returns a normalized search query string that Query parses into a
+// searcher equivalent to this one, including any sub-searches and boolean
+// groups. This is useful for persisting or sharing searches that were built
+// with methods on a Searcher.
+//
+// Text comes first, followed by directives in a fixed order. Settings that
+// have no directive (like GoodThreshold and Chooser) are not included.
+// A negation or alternative of a searcher without any filters cannot be
+// expressed in a query string, so it is omitted.
+func (s *Searcher) Canonical() string {
+	return strings.Join(s.canonical(), " ")
+}
+
+// String returns the canonical search query string of the searcher.
+// (See Canonical.)
+func (s *Searcher) String() string {
+	return s.Canonical()
+}
+
+func (s *Searcher) canonical() []string {
+	var items []string
+	dir := func(name, arg string) {
+		if len(arg) == 0 {
+			items = append(items, sf("{%s}", name))
+		} else {
+			items = append(items, sf("{%s:%s}", name, arg))
+		}
+	}
+	irange := func(name string, ir *irange) {
+		if ir != nil && (ir.min != nil || ir.max != nil) {
+			dir(name, ir.String())
+		}
+	}
+	drange := func(name string, dr *drange) {
+		if dr != nil && (!dr.min.IsZero() || !dr.max.IsZero()) {
+			dir(name, dr.String())
+		}
+	}
+
+	if len(s.name) > 0 {
+		items = append(items, quoteText(strings.Join(s.name, " ")))
+	}
+	if s.atom > 0 {
+		dir("id", sf("%d", s.atom))
+	}
+	for _, e := range s.entities {
+		dir(e.String(), "")
+	}
+	for _, genre := range s.genres {
+		dir("genre", quoteArg(genre))
+	}
+	for _, mpaa := range s.mpaas {
+		dir("mpaa", quoteArg(mpaa))
+	}
+	for _, lang := range s.languages {
+		dir("language", quoteArg(lang))
+	}
+	for _, mix := range s.soundMixes {
+		dir("sound", quoteArg(mix))
+	}
+	if s.color != nil {
+		if *s.color {
+			dir("color", "color")
+		} else {
+			dir("color", "bw")
+		}
+	}
+	for _, ts := range s.texts {
+		dir(ts.table, quoteArg(ts.query))
+	}
+	irange("years", s.year)
+	irange("rank", s.rating)
+	irange("votes", s.votes)
+	irange("polarization", s.polarization)
+	irange("runtime", s.runtime)
+	irange("seasons", s.season)
+	irange("episodes", s.episode)
+	drange("aired", s.aired)
+	drange("released", s.released)
+	if s.noTvMovie {
+		dir("notv", "")
+	}
+	if s.noVideoMovie {
+		dir("novideo", "")
+	}
+	for _, g := range s.groups {
+		if q := g.canonical(); len(q) > 0 {
+			items = append(items, q)
+		}
+	}
+
+	// Everything else changes how the search is run, which only matters for
+	// the searcher being executed.
+	if s.parent != nil {
+		return items
+	}
+	if s.releaseCountry != "" {
+		dir("releasecountry", quoteArg(s.releaseCountry))
+	}
+	if s.subTvshow != nil {
+		dir(s.subTvshow.directive("show"), s.subTvshow.Canonical())
+	}
+	for _, sub := range s.subCredits {
+		dir(sub.directive("credits"), sub.Canonical())
+	}
+	for _, sub := range s.subCast {
+		dir(sub.directive("cast"), sub.Canonical())
+	}
+	if s.anyCredits {
+		dir("anycredits", "")
+	}
+	irange("billing", s.billing)
+	if s.similarThreshold != 0.4 {
+		dir("similar", strconv.FormatFloat(s.similarThreshold, 'g', -1, 64))
+	}
+	if s.weightVotes != 25000 {
+		dir("weightvotes", sf("%d", s.weightVotes))
+	}
+	if s.weightMean >= 0 {
+		dir("weightmean", strconv.FormatFloat(s.weightMean, 'g', -1, 64))
+	}
+	for _, ord := range s.order {
+		dir("sort", sf("%s %s", ord.column, ord.order))
+	}
+	if s.limit != 30 {
+		dir("limit", sf("%d", s.limit))
+	}
+	if s.offset != 0 {
+		dir("offset", sf("%d", s.offset))
+	}
+	if s.debug {
+		dir("debug", "")
+	}
+	return items
+}
+
+// directive returns the name of the directive for a sub-search, given the
+// name of the directive for its mode that isn't set mode.
+func (sub *subsearch) directive(name string) string {
+	if sub.set {
+		return name + "*"
+	}
+	return name
+}
+
+// canonical returns the boolean group as a search query string, or an empty
+// string if it cannot be expressed.
+func (g boolGroup) canonical() string {
+	var branches []string
+	for _, sub := range g.subs {
+		q := sub.Canonical()
+		if len(q) == 0 {
+			return ""
+		}
+		branches = append(branches, q)
+	}
+	if g.not {
+		return sf("{not:%s}", branches[0])
+	}
+	// A group with a single alternative is repeated, since parentheses
+	// around it would otherwise just group it with its parent.
+	if len(branches) == 1 {
+		branches = append(branches, branches[0])
+	}
+	return sf("(%s)", strings.Join(branches, " | "))
+}
+
+func (ir *irange) String() string {
+	switch {
+	case ir.min != nil && ir.max != nil && *ir.min == *ir.max:
+		return sf("%d", *ir.min)
+	case ir.min != nil && ir.max != nil:
+		return sf("%d-%d", *ir.min, *ir.max)
+	case ir.min != nil:
+		return sf("%d-", *ir.min)
+	case ir.max != nil:
+		return sf("-%d", *ir.max)
+	}
+	return ""
+}
+
+func (dr *drange) String() string {
+	const layout = "2006-01-02"
+	switch {
+	case !dr.min.IsZero() && dr.min.Equal(dr.max):
+		return dr.min.Format(layout)
+	case !dr.min.IsZero() && !dr.max.IsZero():
+		return dr.min.Format(layout) + ".." + dr.max.Format(layout)
+	case !dr.min.IsZero():
+		return dr.min.Format(layout) + ".."
+	case !dr.max.IsZero():
+		return ".." + dr.max.Format(layout)
+	}
+	return ""
+}
+
+// quoteText returns text as it should appear in a search query. Text is
+// quoted if it has any characters that would otherwise be interpreted
+// specially, or if its whitespace would not be preserved.
+func quoteText(text string) string {
+	if strings.Join(strings.Fields(text), " ") == text &&
+		!strings.ContainsAny(text, `(){}|"\`) {
+		return text
+	}
+	return quote(text)
+}
+
+// quoteArg returns the argument of a directive as it should appear in a
+// search query.
+func quoteArg(arg string) string {
+	if strings.TrimSpace(arg) == arg && !strings.ContainsAny(arg, `{}"\`) {
+		return arg
+	}
+	return quote(arg)
+}
+
+// quote surrounds s with double quotes, escaping any double quotes or
+// backslashes in s.
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	return `"` + s + `"`
+}
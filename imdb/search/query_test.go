@@ -0,0 +1,297 @@
+package search
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+// commandArgs has an example argument for every search directive that
+// requires one.
+var commandArgs = map[string]string{
+	"genre":          "comedy",
+	"mpaa":           "R",
+	"language":       "English",
+	"sound":          "Dolby Digital",
+	"color":          "bw",
+	"plot":           `time "travel"`,
+	"quote":          "we need a bigger boat",
+	"trivia":         "cameo",
+	"goof":           "boom mic",
+	"tagline":        "in space",
+	"credits":        "the matrix {movie} {years:1999}",
+	"cast":           `"tom hanks" {votes:100-}`,
+	"show":           "the simpsons {rank:80-}",
+	"credits*":       "{genre:horror} {years:1980-1989}",
+	"cast*":          "kevin bacon {votes:100-}",
+	"show*":          "star trek",
+	"not":            "{genre:animation} {years:-2000}",
+	"id":             "42",
+	"years":          "1990-1999",
+	"rank":           "80-",
+	"votes":          "-1000",
+	"polarization":   "30",
+	"runtime":        "90-120",
+	"billing":        "1-5",
+	"seasons":        "1-5",
+	"episodes":       "3",
+	"aired":          "2000..2004-06",
+	"released":       "..1999-12-31",
+	"releasecountry": "USA",
+	"similar":        "0.75",
+	"weightvotes":    "1000",
+	"weightmean":     "62.5",
+	"limit":          "5",
+	"offset":         "10",
+	"sort":           "year desc",
+}
+
+func TestCanonicalCommands(t *testing.T) {
+	db := testDB()
+	for _, cmd := range Commands {
+		q := "{" + cmd.Name + "}"
+		if allCommands[cmd.Name].hasArg {
+			arg, ok := commandArgs[cmd.Name]
+			if !ok {
+				t.Errorf("No example argument for '%s'.", cmd.Name)
+				continue
+			}
+			q = "{" + cmd.Name + ":" + arg + "}"
+		}
+		testRoundTrip(t, db, "matrix "+q)
+		for _, syn := range cmd.Synonyms {
+			testRoundTrip(t, db, "matrix "+q[0:1]+syn+q[1+len(cmd.Name):])
+		}
+	}
+}
+
+func TestCanonicalQueries(t *testing.T) {
+	db := testDB()
+	queries := []string{
+		"",
+		"the matrix",
+		`"(500) days of summer"`,
+		`c\"mon {movie}`,
+		"{sort:rank desc} {sort:votes desc} {limit:10} {tvshow}",
+		"{genre:drama} ({genre:comedy} | {years:2000-} {rank:70-})",
+		"{-genre:horror} {-years:1980-1989}",
+		"{not:{genre:animation} ({mpaa:G} | {mpaa:PG})}",
+		"{show:{not:{years:2010-}} simpsons} {seasons:2}",
+		"{cast:{credits:{movie} the matrix} keanu} {episode}",
+		"{cast:keanu reeves} {cast:carrie-anne moss} {anycast} {billing:1-3}",
+		"{show*:star trek {tvshow}} {cast*:{credits*:{genre:horror}}}",
+		`{plot:"a \"quoted\" word"} {language:"{odd}"}`,
+		"{aired:2001-09-11} {released:1999..} {color:colour}",
+		"{similar:0.4} {limit:30} {weightvotes:25000}",
+	}
+	for _, q := range queries {
+		testRoundTrip(t, db, q)
+	}
+}
+
+func TestCanonicalMethods(t *testing.T) {
+	db := testDB()
+	s := New(db).Text("star wars").Entity(imdb.EntityMovie)
+	s.Years(1977, 1983).Not(New(db).Genre("animation"))
+	s.Or(New(db).Text("hope"))
+	s.Cast(New(db).Text("harrison ford"))
+	want := "star wars {movie} {years:1977-1983} " +
+		"{not:{genre:animation}} (hope | hope) " +
+		"{cast:harrison ford {actor}}"
+	if got := s.Canonical(); got != want {
+		t.Errorf("Expected canonical query\n%s\nbut got\n%s", want, got)
+	}
+	testRoundTrip(t, db, s.Canonical())
+}
+
+func TestQueryErrors(t *testing.T) {
+	tests := []struct {
+		query  string
+		column int
+		err    string
+	}{
+		{"foo {years:abc}", 5, "'abc' as integer"},
+		{"(a | b", 1, "missing ')'"},
+		{"a (b (c) d", 3, "missing ')'"},
+		{"a) b", 2, "unexpected ')'"},
+		{"a | | b", 5, "must be non-empty"},
+		{"a |", 1, "must be non-empty"},
+		{`"the matrix`, 1, "Unterminated quoted string"},
+		{`x "a \" b`, 3, "Unterminated quoted string"},
+		{"x {genre:comedy", 3, "Unterminated directive"},
+		{"x }", 3, "Unexpected '}'"},
+		{"{}", 1, "must have a name"},
+		{"bar {foo:1}", 5, "Unrecognized search option: foo"},
+		{"{-foo}", 1, "Unrecognized search option: foo"},
+		{"{genre}", 1, "requires an argument"},
+		{"{movie:1}", 1, "does not have an argument"},
+		{"{-sort:year}", 1, "cannot be negated"},
+		{"a -{limit:5}", 3, "cannot be negated"},
+		{"a ({limit:5} | b)", 4, "cannot be negated or used in a group"},
+		{"{not:{limit:5}}", 6, "cannot be negated or used in a group"},
+		{"{cast:{foo}}", 7, "Unrecognized search option: foo"},
+		{"{credits:a (b}", 12, "missing ')'"},
+		{"é {foo}", 3, "Unrecognized search option: foo"},
+	}
+	for _, test := range tests {
+		_, err := Query(testDB(), test.query)
+		qe, ok := err.(*QueryError)
+		if !ok {
+			t.Errorf("Query '%s' should have an error at column %d, "+
+				"but got: %v", test.query, test.column, err)
+			continue
+		}
+		if qe.Column != test.column || !strings.Contains(qe.Error(), test.err) {
+			t.Errorf("Query '%s' should have an error containing '%s' at "+
+				"column %d, but got: %s", test.query, test.err, test.column, qe)
+		}
+	}
+}
+
+func TestQueryQuoting(t *testing.T) {
+	tests := []struct {
+		query, tree string
+	}{
+		{`"the matrix" reloaded`, `"the matrix" "reloaded"`},
+		{`c\"mon`, `"c\"mon"`},
+		{`"a \"b\" \\c"`, `"a \"b\" \\c"`},
+		{`\{x\} \(y\) a\|b`, `"{x}" "(y)" "a|b"`},
+		{`"a | (b) {c}"`, `"a | (b) {c}"`},
+		{`(500) days`, `("500") "days"`},
+		{`{plot:"a \"quoted\" word"}`, `{plot:a "quoted" word}`},
+		{`{language:"{odd}"}`, `{language:{odd}}`},
+		{`{ genre : comedy }`, `{genre:comedy}`},
+		{`{cast:"tom hanks" {votes:100-}}`, `{cast:"tom hanks" {votes:100-}}`},
+	}
+	for _, test := range tests {
+		g, err := parseQuery(test.query, 0)
+		if err != nil {
+			t.Errorf("Could not parse '%s': %s", test.query, err)
+			continue
+		}
+		if got := tree(g); got != test.tree {
+			t.Errorf("Query '%s' should parse to\n%s\nbut got\n%s",
+				test.query, test.tree, got)
+		}
+	}
+}
+
+func TestQueryGroups(t *testing.T) {
+	tests := []struct {
+		query, tree string
+	}{
+		// A '-' only negates directives. Before text, it's part of the text.
+		{"-foo", `"-foo"`},
+		{"a | b", `"a" | "b"`},
+		{"(a | b) -{tvshow}", `("a" | "b") {-tvshow}`},
+		{"{-genre:horror} -{years:1980-1989}",
+			`{-genre:horror} {-years:1980-1989}`},
+		{"a ({movie} | {tvshow} {years:2000-}) b",
+			`"a" ({movie} | {tvshow} {years:2000-}) "b"`},
+		{"((a) | b)", `(("a") | "b")`},
+		{"{not:{genre:animation} | {mpaa:G}}",
+			`{not:{genre:animation} | {mpaa:G}}`},
+	}
+	for _, test := range tests {
+		g, err := parseQuery(test.query, 0)
+		if err != nil {
+			t.Errorf("Could not parse '%s': %s", test.query, err)
+			continue
+		}
+		if got := tree(g); got != test.tree {
+			t.Errorf("Query '%s' should parse to\n%s\nbut got\n%s",
+				test.query, test.tree, got)
+		}
+	}
+}
+
+// tree returns the syntax tree of a search query in a compact form, where
+// text is quoted and nested groups are parenthesized.
+func tree(g *queryGroup) string {
+	var branches []string
+	for _, branch := range g.branches {
+		var nodes []string
+		for _, node := range branch {
+			switch {
+			case node.text != nil:
+				nodes = append(nodes, strconv.Quote(node.text.text))
+			case node.directive != nil:
+				d := node.directive
+				dir := d.name
+				if d.negated {
+					dir = "-" + dir
+				}
+				if len(d.arg) > 0 {
+					dir += ":" + d.arg
+				}
+				nodes = append(nodes, "{"+dir+"}")
+			default:
+				nodes = append(nodes, "("+tree(node.group)+")")
+			}
+		}
+		branches = append(branches, strings.Join(nodes, " "))
+	}
+	return strings.Join(branches, " | ")
+}
+
+// testRoundTrip checks that the canonical form of the query given parses to
+// an equivalent searcher, and that it is a fixed point.
+func testRoundTrip(t *testing.T, db *imdb.DB, q string) {
+	s1, err := Query(db, q)
+	if err != nil {
+		t.Errorf("Could not parse '%s': %s", q, err)
+		return
+	}
+	canon := s1.Canonical()
+	s2, err := Query(db, canon)
+	if err != nil {
+		t.Errorf("Could not parse canonical query '%s' of '%s': %s",
+			canon, q, err)
+		return
+	}
+	if again := s2.Canonical(); again != canon {
+		t.Errorf("Canonical query of '%s' is '%s', but it changed to '%s'.",
+			q, canon, again)
+	}
+	sql1, sql2 := s1.sql(), s2.sql()
+	if sql1 != sql2 || !reflect.DeepEqual(s1.args, s2.args) {
+		t.Errorf("Query '%s' and its canonical query '%s' differ:\n"+
+			"%s\n%v\n\n%s\n%v", q, canon, sql1, s1.args, sql2, s2.args)
+	}
+}
+
+// testDB returns a database that fails every query, which is enough to build
+// searchers and generate SQL without a real database.
+func testDB() *imdb.DB {
+	db, err := sql.Open("goimtest", "")
+	if err != nil {
+		panic(err)
+	}
+	return &imdb.DB{DB: db, Driver: "postgres"}
+}
+
+func init() {
+	sql.Register("goimtest", testDriver{})
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("no database")
+}
+
+func (testConn) Close() error { return nil }
+
+func (testConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("no database")
+}
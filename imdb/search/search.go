@@ -1,10 +1,11 @@
 package search
 
 import (
-	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/BurntSushi/ty/fun"
 
@@ -36,12 +37,30 @@ type Result struct {
 	// Similarity corresponds to the amount of similarity between the name
 	// given in the query and the name returned in this result.
 	// This is set to -1 when fuzzy searching is not available (e.g., for
-	// SQLite or Postgres when the 'pg_trgm' extension isn't enabled).
+	// Postgres when the 'pg_trgm' extension isn't enabled, or SQLite when
+	// names haven't been indexed with imdb.DB.IndexTrigrams).
 	Similarity float64
 
 	// If an IMDb rank exists for a search result, it will be stored here.
 	Rank imdb.UserRank
 
+	// Weighted is the Bayesian weighted rank of the search result on the
+	// same scale as Rank.Rank. It is only computed when the results are
+	// sorted by the "weighted" field. Otherwise, it is 0.
+	// (See Searcher.WeightVotes for details.)
+	Weighted float64
+
+	// Released is the earliest release date of the search result. It is only
+	// computed when the search is restricted or sorted by release date.
+	// (See Searcher.Released and Searcher.ReleaseCountry.) Otherwise, it is
+	// the zero time.
+	Released time.Time
+
+	// Snippet is an excerpt of the text matched by the first full-text
+	// search in the query (e.g., {plot:...}), with the matching words
+	// surrounded by '[' and ']'. It is empty otherwise.
+	Snippet string
+
 	// If the search accesses credit information, then it will be stored here.
 	Credit Credit
 }
@@ -78,6 +97,7 @@ func (sr Result) String() string {
 type Searcher struct {
 	db                              *imdb.DB
 	fuzzy                           bool     // whether to use fuzzy searching
+	nameKeys                        bool     // whether names have search keys
 	name                            []string // text to search in name table
 	what                            string   // used to identify sub-searches
 	debug                           bool     // whether to output SQL query
@@ -85,15 +105,49 @@ type Searcher struct {
 	entities                        []imdb.EntityKind
 	genres                          []string
 	mpaas                           []string
+	languages                       []string
+	soundMixes                      []string
+	color                           *bool
+	releaseCountry                  string
+	texts                           []textSearch
 	order                           []searchOrder
-	limit                           int
+	limit, offset                   int
 	goodThreshold, similarThreshold float64
+	weightVotes                     int
+	weightMean                      float64
 	chooser                         Chooser
+	macros                          Macros
 
-	subTvshow, subCredits, subCast                *subsearch
+	subTvshow                                     *subsearch
+	subCredits, subCast                           []*subsearch
 	year, rating, votes, season, episode, billing *irange
+	polarization, runtime                         *irange
+	aired, released                               *drange
 
 	noTvMovie, noVideoMovie bool
+
+	// anyCredits is set when results need only satisfy one of the credits
+	// and cast sub-searches, rather than all of them. (See AnyCredits.)
+	anyCredits bool
+
+	// groups are boolean combinations of the filters in other searchers.
+	// (See Not and Or.) parent is set for those searchers, so that their
+	// query parameters are bound in the search being executed.
+	groups []boolGroup
+	parent *Searcher
+
+	// args holds the values bound to parameters in the SQL query. It is
+	// rebuilt every time the query is generated. argOffset is the number of
+	// parameters before them when the query is part of the query of another
+	// search. (See setSQL.)
+	args      []interface{}
+	argOffset int
+
+	// wrapped is set while the search query is generated as a subquery of
+	// another query that does its own sorting and limiting. (See Page, Count
+	// and Facets.) keys is set while a page of results is retrieved.
+	wrapped bool
+	keys    []pageKey
 }
 
 // Chooser corresponds to a function called by the searcher in this
@@ -126,15 +180,49 @@ type irange struct {
 	min, max *int
 }
 
+// boolGroup represents a boolean combination of the filters of one or more
+// searchers. When not is true, it corresponds to the negation of the filters
+// in its only searcher. Otherwise, it is the disjunction of its searchers.
+type boolGroup struct {
+	not  bool
+	subs []*Searcher
+}
+
+// drange represents a range of dates for a particular attribute. A zero time
+// leaves that end of the range unbounded.
+type drange struct {
+	min, max time.Time
+}
+
+// textSearch represents a full-text search of the prose in one of the
+// plot, quote, trivia, goof or tagline tables.
+type textSearch struct {
+	table, query string
+}
+
+// textColumns maps each table that can be searched with full-text search to
+// the column containing its text.
+var textColumns = map[string]string{
+	"plot":    "entry",
+	"quote":   "entry",
+	"trivia":  "entry",
+	"goof":    "entry",
+	"tagline": "tag",
+}
+
 // subsearch represents an optionally empty sub-search. A sub-search is just
 // like a regular search, except it filters the results of its parent search.
 // Every sub-search (just like a regular search) returns results of entities
 // which are shrunk to either 0 or 1 entities. If 0, then the entire search
 // will fail. If 1, then the 'id' field is filled in with the corresponding
 // atom identifier.
+//
+// A sub-search in set mode is never shrunk. Instead, every one of its results
+// is used by its parent search. (See setSQL.)
 type subsearch struct {
 	*Searcher
-	id imdb.Atom // -1 will cause the parent search to fail.
+	id  imdb.Atom // -1 will cause the parent search to fail.
+	set bool
 }
 
 // New returns a bare-bones searcher with no text to search. Once all options
@@ -146,9 +234,12 @@ func New(db *imdb.DB) *Searcher {
 	return &Searcher{
 		db:               db,
 		fuzzy:            db.IsFuzzyEnabled(),
+		nameKeys:         db.HasNameKeys(),
 		limit:            30,
 		goodThreshold:    0.25,
 		similarThreshold: 0.4,
+		weightVotes:      25000,
+		weightMean:       -1,
 		what:             "entity",
 	}
 }
@@ -162,16 +253,34 @@ func New(db *imdb.DB) *Searcher {
 // A directive begins and ends with '{' and '}' and is of the form
 // {NAME[:ARGUMENT]}, where NAME is the name of the directive and argument
 // is an argument for the directive. Each directive either requires no argument
-// or requires a single argument.
+// or requires a single argument. Arguments of the directives that are
+// sub-searches (like {cast:...}) are search queries themselves, and may
+// contain nested directives.
+//
+// Text is whitespace delimited, unless it is surrounded by double quotes.
+// Inside or outside of quotes, any character preceded by a backslash is
+// treated as text. This makes it possible to search for names containing
+// special characters like '{' or '|'. Alternatives may be separated with '|'
+// and grouped with parentheses, and directives may be negated with a '-'
+// before their name. (See 'goim help search' for details.)
+//
+// If the query is malformed, then the error returned is a *QueryError with
+// the position of the problem in the query.
+//
+// Queries may also reference macros with directives of the form {@NAME}.
+// Macros must be given to a searcher with the Macros method before calling
+// its Query method. (See Macros.)
 //
 // Tokens in the query that aren't directives are appended together and used
-// as text to search against all entity names. This text may be empty. If the
-// text contains the wildcards '%' (to match any sequence of characters) or
-// '_' (to match any single character), then the database's substring matching
-// operator is used (always case insensitive). Otherwise, fuzzy searching is
-// used when it's enabled (which is only possible with PostgreSQL and the
-// 'pg_trgm' extension). If fuzzy searching isn't available, regular string
-// equality is used.
+// as text to search against all entity names. This text may be empty. The
+// text is matched against the search keys of names rather than the names
+// themselves, so case, accents, punctuation and leading articles are ignored.
+// (See imdb.NameKey.) If the text contains the wildcards '%' (to match any
+// sequence of characters) or '_' (to match any single character), then the
+// database's substring matching operator is used. Otherwise, fuzzy searching
+// is used when it's enabled (which requires the 'pg_trgm' extension with
+// PostgreSQL, or an index of name trigrams with SQLite). If fuzzy searching
+// isn't available, regular string equality is used.
 //
 // Query is the equivalent of calling New(db).Query(query).
 //
@@ -186,14 +295,19 @@ func Query(db *imdb.DB, query string) (*Searcher, error) {
 // package level Query function for details on the format of the search query
 // string.
 //
+// Any macros in the query are expanded before it is parsed. (See Macros.)
+//
 // It is safe to give untrusted input as a query.
 func (s *Searcher) Query(query string) error {
-	for _, arg := range queryTokens(query) {
-		if err := s.addToken(arg); err != nil {
-			return err
-		}
+	query, err := s.root().macros.Expand(query)
+	if err != nil {
+		return err
 	}
-	return nil
+	g, err := parseQuery(query, 0)
+	if err != nil {
+		return err
+	}
+	return g.apply(s)
 }
 
 // Text adds the given string to the query string as plain text. It is not
@@ -207,22 +321,21 @@ func (s *Searcher) Text(text string) *Searcher {
 	return s
 }
 
-func (s *Searcher) addToken(arg string) error {
-	name, val := argOption(arg)
-	if cmd, ok := allCommands[name]; ok {
-		if cmd.hasArg && len(val) == 0 {
-			return ef("The %s command requires an argument.", name)
-		} else if !cmd.hasArg && len(val) > 0 {
-			return ef("The %s command does not have an argument.", name)
-		}
-		return cmd.add(s, val)
-	} else {
-		if len(name) > 0 {
-			return ef("Unrecognized search option: %s", name)
-		}
-		s.Text(arg)
-		return nil
+// child returns a new searcher for use in a boolean group of this searcher.
+func (s *Searcher) child() *Searcher {
+	return &Searcher{
+		db: s.db, fuzzy: s.fuzzy, nameKeys: s.nameKeys, what: s.what,
+		parent: s,
+	}
+}
+
+// root returns the searcher that is executed, which is the searcher itself
+// unless it is in a boolean group.
+func (s *Searcher) root() *Searcher {
+	if s.parent != nil {
+		return s.parent.root()
 	}
+	return s
 }
 
 func (s *Searcher) subSearcher(name, query string) (*Searcher, error) {
@@ -231,54 +344,104 @@ func (s *Searcher) subSearcher(name, query string) (*Searcher, error) {
 	}
 	sub, err := Query(s.db, query)
 	if err != nil {
-		return nil, ef("Error with sub-search for %s: %s", name, err)
+		return nil, wrapQueryError(err, "Error with sub-search for %s", name)
 	}
 	return sub, nil
 }
 
 // Results executes the parameters of the search and returns the results.
 func (s *Searcher) Results() (rs []Result, err error) {
+	err = s.Each(func(r Result) error {
+		rs = append(rs, r)
+		return nil
+	})
+	return
+}
+
+// ErrStop can be returned by the function given to Each to stop iterating
+// over results without causing Each to return an error.
+var ErrStop = errors.New("stop iterating over search results")
+
+// Each executes the parameters of the search and calls fn for each result as
+// it is read from the database. Unlike Results, the results are never all
+// kept in memory, which makes Each suitable for searches with a lot of
+// results.
+//
+// If fn returns an error, then iteration stops and the error is returned by
+// Each, unless it is ErrStop.
+func (s *Searcher) Each(fn func(Result) error) (err error) {
 	defer csql.Safe(&err)
 
-	// Set the similarity threshold first.
-	if s.db.IsFuzzyEnabled() {
-		csql.Exec(s.db, "SELECT set_limit($1)", s.similarThreshold)
+	if err := s.prepare(); err != nil {
+		return err
+	}
+	q := s.sql()
+	rows := csql.Query(s.db, q, s.args...)
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(scanResult(rows)); err != nil {
+			if err == ErrStop {
+				return nil
+			}
+			return err
+		}
 	}
+	csql.Panic(rows.Err())
+	return nil
+}
 
+// prepare does everything that must be done before the query of the search
+// is executed, like resolving sub-searches. Database errors cause a panic
+// that should be recovered with csql.Safe.
+func (s *Searcher) prepare() error {
 	if s.subTvshow != nil {
-		if err := s.subTvshow.choose(s, s.chooser); err != nil {
-			return nil, err
+		if err := s.subTvshow.resolve(s); err != nil {
+			return err
 		}
 	}
-	if s.subCredits != nil {
-		if err := s.subCredits.choose(s, s.chooser); err != nil {
-			return nil, err
+	for _, sub := range s.creditSubs() {
+		if err := sub.resolve(s); err != nil {
+			return err
 		}
 	}
-	if s.subCast != nil {
-		if err := s.subCast.choose(s, s.chooser); err != nil {
-			return nil, err
-		}
+
+	// The similarity threshold is set after sub-searches are resolved, since
+	// they set their own. (With SQLite, the threshold is part of the query.)
+	if s.db.Driver == "postgres" && s.db.IsFuzzyEnabled() {
+		csql.Exec(s.db, "SELECT set_limit($1)", s.similarThreshold)
 	}
 
-	var rows *sql.Rows
-	if len(s.name) == 0 {
-		rows = csql.Query(s.db, s.sql())
-	} else {
-		rows = csql.Query(s.db, s.sql(), strings.Join(s.name, " "))
-	}
-	csql.ForRow(rows, func(scanner csql.RowScanner) {
-		var r Result
-		var ent string
-		csql.Scan(scanner, &ent, &r.Id, &r.Name, &r.Year,
-			&r.Similarity, &r.Attrs,
-			&r.Rank.Votes, &r.Rank.Rank,
-			&r.Credit.ActorId, &r.Credit.MediaId, &r.Credit.Character,
-			&r.Credit.Position, &r.Credit.Attrs)
-		r.Entity = imdb.Entities[ent]
-		rs = append(rs, r)
-	})
-	return
+	// The prior mean of the weighted rank is only computed when it's needed,
+	// since it requires a pass over the entire rating table.
+	if s.weighted() && s.weightMean < 0 {
+		q := "SELECT COALESCE(AVG(rank), 0) FROM rating"
+		csql.Scan(s.db.QueryRow(q), &s.weightMean)
+	}
+
+	if len(s.texts) > 0 && !s.db.IsTextSearchEnabled() {
+		return ef("Full-text search is not available. (SQLite " +
+			"must be compiled with FTS5.)")
+	}
+	return nil
+}
+
+// scanResult scans a row of the search query into a result. Any extra
+// destinations given are scanned from the columns following the columns of
+// the result.
+func scanResult(scanner csql.RowScanner, extra ...interface{}) Result {
+	var r Result
+	var ent string
+	dests := []interface{}{
+		&ent, &r.Id, &r.Name, &r.Year,
+		&r.Similarity, &r.Attrs,
+		&r.Rank.Votes, &r.Rank.Rank, &r.Rank.Distribution, &r.Weighted,
+		nullTime{&r.Released}, &r.Snippet,
+		&r.Credit.ActorId, &r.Credit.MediaId, &r.Credit.Character,
+		&r.Credit.Position, &r.Credit.Attrs,
+	}
+	csql.Scan(scanner, append(dests, extra...)...)
+	r.Entity = imdb.Entities[ent]
+	return r
 }
 
 // Pick returns the best match in a list of results. If results is empty, then
@@ -315,7 +478,30 @@ func (s *Searcher) Pick(rs []Result) (*Result, error) {
 	return r, nil
 }
 
+// resolve does everything that must be done with a sub-search before its
+// parent search is executed. A sub-search in set mode isn't shrunk to a single
+// entity, but its own sub-searches must still be resolved.
+func (sub *subsearch) resolve(parent *Searcher) error {
+	if !sub.set {
+		return sub.choose(parent, parent.chooser)
+	}
+	// Its results are part of the query of its parent, so it must use the
+	// same similarity threshold.
+	sub.chooser = parent.chooser
+	sub.debug = parent.debug
+	sub.similarThreshold = parent.similarThreshold
+	if err := sub.prepare(); err != nil {
+		return ef("Error with %s sub-search: %s", sub.what, err)
+	}
+	return nil
+}
+
 func (sub *subsearch) choose(parent *Searcher, chooser Chooser) error {
+	// A sub-search is only resolved once, so that the chooser isn't called
+	// again when a search is executed more than once. (e.g., by Suggest.)
+	if sub.id != 0 {
+		return nil
+	}
 	sub.goodThreshold = parent.goodThreshold
 	sub.chooser = parent.chooser
 	sub.debug = parent.debug
@@ -340,6 +526,19 @@ func (sub *subsearch) empty() bool {
 	return sub == nil || sub.id == 0
 }
 
+// setSQL returns a query selecting the atom identifier of every result of a
+// sub-search in set mode (regardless of its sort criteria and limit), for use
+// in the query of the searcher given. The parameters of the sub-search are
+// numbered after those already bound in the searcher given, and are then
+// bound in it.
+func (sub *subsearch) setSQL(s *Searcher) string {
+	root := s.root()
+	sub.argOffset = root.argOffset + len(root.args)
+	q := sub.wrappedSQL()
+	root.args = append(root.args, sub.args...)
+	return sf("SELECT atom_id FROM (%s) AS sub", q)
+}
+
 // GoodThreshold sets the threshold at which a result is considered "good"
 // relative to other results returned. This is used to automatically pick a
 // good hit from sub-searches (like for a TV show). Namely, if the difference
@@ -367,6 +566,11 @@ func (s *Searcher) SimilarThreshold(t float64) *Searcher {
 // entities in the search will be returned. This function may called more than
 // once to specify additional entities to allow.
 func (s *Searcher) Entity(e imdb.EntityKind) *Searcher {
+	for _, e2 := range s.entities {
+		if e == e2 {
+			return s
+		}
+	}
 	s.entities = append(s.entities, e)
 	return s
 }
@@ -397,6 +601,32 @@ func (s *Searcher) MPAA(name string) *Searcher {
 	return s
 }
 
+// Language adds the named language to the search. Results only in the
+// language given are returned. If multiple languages are specified in the
+// search, then they are combined disjunctively.
+// The language name is matched case insensitively. (e.g., "french".)
+func (s *Searcher) Language(name string) *Searcher {
+	s.languages = append(s.languages, strings.ToLower(name))
+	return s
+}
+
+// SoundMix adds the sound mix to the search. Results only with a sound mix
+// containing the text given (case insensitive) are returned. For example,
+// "dolby" matches both "Dolby" and "Dolby Digital". If multiple sound mixes
+// are specified in the search, then they are combined disjunctively.
+func (s *Searcher) SoundMix(mix string) *Searcher {
+	s.soundMixes = append(s.soundMixes, strings.ToLower(mix))
+	return s
+}
+
+// Color specifies that the results must be in color (when color is true) or
+// in black and white (when color is false). Note that media with both color
+// and black and white footage match either.
+func (s *Searcher) Color(color bool) *Searcher {
+	s.color = &color
+	return s
+}
+
 // Atom specifies that the result returned must have the atom identifier
 // given. Note that this guarantees that the number of results will either
 // be 0 or 1.
@@ -429,6 +659,44 @@ func (s *Searcher) Episodes(min, max int) *Searcher {
 	return s
 }
 
+// Aired specifies that the results must be episodes that aired in the range
+// of dates given. The range is inclusive. Note that episodes with an unknown
+// air date are never returned.
+// Either min or max can be disabled with a zero time.
+func (s *Searcher) Aired(min, max time.Time) *Searcher {
+	s.aired = &drange{min, max}
+	return s
+}
+
+// Runtimes specifies that the results must have a running time, in minutes,
+// in the range given. If a result has more than one running time (e.g., for
+// different countries or cuts), then only one of them needs to match.
+// The range is inclusive.
+// Either min or max can be disabled with a value of -1.
+func (s *Searcher) Runtimes(min, max int) *Searcher {
+	s.runtime = newIrange(min, max)
+	return s
+}
+
+// Released specifies that the results must have been first released in the
+// range of dates given. The first release of a result is its earliest release
+// date (see ReleaseCountry). The range is inclusive. Note that results
+// without any release dates are never returned.
+// Either min or max can be disabled with a zero time.
+func (s *Searcher) Released(min, max time.Time) *Searcher {
+	s.released = &drange{min, max}
+	return s
+}
+
+// ReleaseCountry restricts the release dates used to find the first release
+// of each result to those in the country given (case insensitive). This
+// affects both the Released range and sorting by the "released" field.
+// e.g., "USA" finds premieres in the United States.
+func (s *Searcher) ReleaseCountry(country string) *Searcher {
+	s.releaseCountry = strings.ToLower(country)
+	return s
+}
+
 // NoTvMovies filters out "made for TV" movies from a search.
 func (s *Searcher) NoTvMovies() *Searcher {
 	s.noTvMovie = true
@@ -458,6 +726,61 @@ func (s *Searcher) Votes(min, max int) *Searcher {
 	return s
 }
 
+// Polarization specifies that the results must have a polarization in the
+// range given. Polarization is the approximate percentage of votes with a
+// score of either 1 or 10 (see imdb.VoteDistribution), which is useful for
+// finding divisive or cult media.
+// The range is inclusive.
+// Either min or max can be disabled with a value of -1.
+func (s *Searcher) Polarization(min, max int) *Searcher {
+	s.polarization = newIrange(min, max)
+	return s
+}
+
+// WeightVotes sets the minimum number of votes used to compute the Bayesian
+// weighted rank of each result. The weighted rank is used when sorting by the
+// "weighted" field, and is computed as
+//
+//	(v / (v + m)) * R + (m / (v + m)) * C
+//
+// where R is the rank of the result, v is the number of votes for the result,
+// m is the minimum number of votes given here and C is the prior mean (see
+// WeightMean). Intuitively, results with few votes are pulled toward the
+// mean, which makes for a much better chart than sorting by rank alone.
+//
+// By default, the minimum is 25,000 votes.
+func (s *Searcher) WeightVotes(n int) *Searcher {
+	s.weightVotes = n
+	return s
+}
+
+// WeightMean sets the prior mean rank used to compute the Bayesian weighted
+// rank of each result. (See WeightVotes.) The mean should be on the same scale
+// as ranks: 0 to 100.
+//
+// By default (or if the mean is negative), the prior mean is the average rank
+// of all entities in the rating table.
+func (s *Searcher) WeightMean(mean float64) *Searcher {
+	s.weightMean = mean
+	return s
+}
+
+// Fulltext specifies that the results must have text matching the query given
+// in the table named. The table must be one of "plot", "quote", "trivia",
+// "goof" or "tagline". Otherwise, it will be silently ignored. Each word in
+// the query must appear in the text, but not necessarily in order.
+// If Fulltext is called more than once, then the searches are combined
+// conjunctively.
+//
+// Full-text search requires the full-text indices created by 'goim load'.
+// With SQLite, they are only available if it was compiled with FTS5.
+func (s *Searcher) Fulltext(table, query string) *Searcher {
+	if _, ok := textColumns[table]; ok {
+		s.texts = append(s.texts, textSearch{table, query})
+	}
+	return s
+}
+
 // Billed specifies that the results---when they correspond to credits---must
 // be in the billed range provided. For example, when showing credits for an
 // actor, this will restrict the results to movies where the actor has a billed
@@ -478,7 +801,17 @@ func (s *Searcher) Billed(min, max int) *Searcher {
 func (s *Searcher) Tvshow(tvs *Searcher) *Searcher {
 	tvs.Entity(imdb.EntityTvshow)
 	tvs.what = "TV show"
-	s.subTvshow = &subsearch{tvs, 0}
+	s.subTvshow = &subsearch{tvs, 0, false}
+	return s
+}
+
+// TvshowSet is like Tvshow, except that the sub-search is in set mode: the
+// results are restricted to episodes of every TV show returned by the
+// sub-search, rather than a single one. The sub-search's sort criteria and
+// limit are ignored, and its similarity threshold is that of its parent.
+func (s *Searcher) TvshowSet(tvs *Searcher) *Searcher {
+	s.Tvshow(tvs)
+	s.subTvshow.set = true
 	return s
 }
 
@@ -487,9 +820,24 @@ func (s *Searcher) Tvshow(tvs *Searcher) *Searcher {
 // include credits for the entity. (Note that TV shows generally don't have
 // credits associated with them.)
 // If no entity is found, then the parent search quits and returns no results.
+//
+// This may be called more than once, in which case results must have credits
+// for every entity returned. (Unless AnyCredits is used.)
 func (s *Searcher) Credits(credits *Searcher) *Searcher {
 	credits.what = "credits"
-	s.subCredits = &subsearch{credits, 0}
+	s.subCredits = append(s.subCredits, &subsearch{credits, 0, false})
+	return s
+}
+
+// CreditsSet is like Credits, except that the sub-search is in set mode: the
+// results are restricted to actors with credits for any entity returned by
+// the sub-search, rather than a single one. The sub-search's sort criteria
+// and limit are ignored, and its similarity threshold is that of its parent.
+// The credit information of results is not filled in for a sub-search in set
+// mode.
+func (s *Searcher) CreditsSet(credits *Searcher) *Searcher {
+	s.Credits(credits)
+	s.subCredits[len(s.subCredits)-1].set = true
 	return s
 }
 
@@ -498,10 +846,67 @@ func (s *Searcher) Credits(credits *Searcher) *Searcher {
 // include credits for the cast member.
 // If no cast member is found, then the parent search quits and returns no
 // results.
+//
+// This may be called more than once, in which case results must have credits
+// for every cast member returned. (Unless AnyCredits is used.) For example,
+// movies with both Keanu Reeves and Carrie-Anne Moss can be found with two
+// cast sub-searches.
 func (s *Searcher) Cast(cast *Searcher) *Searcher {
 	cast.what = "actor"
 	cast.Entity(imdb.EntityActor)
-	s.subCast = &subsearch{cast, 0}
+	s.subCast = append(s.subCast, &subsearch{cast, 0, false})
+	return s
+}
+
+// CastSet is like Cast, except that the sub-search is in set mode: the
+// results are restricted to media with credits for any cast member returned
+// by the sub-search, rather than a single one. The sub-search's sort criteria
+// and limit are ignored, and its similarity threshold is that of its parent.
+// The credit information of results is not filled in for a sub-search in set
+// mode.
+func (s *Searcher) CastSet(cast *Searcher) *Searcher {
+	s.Cast(cast)
+	s.subCast[len(s.subCast)-1].set = true
+	return s
+}
+
+// AnyCredits specifies that results need only satisfy one of the credits and
+// cast sub-searches, rather than all of them. For example, with two cast
+// sub-searches, results have either cast member. A sub-search that returns no
+// results doesn't stop the search in this case.
+func (s *Searcher) AnyCredits() *Searcher {
+	s.anyCredits = true
+	return s
+}
+
+// creditSubs returns the cast sub-searches followed by the credits
+// sub-searches.
+func (s *Searcher) creditSubs() []*subsearch {
+	subs := make([]*subsearch, 0, len(s.subCast)+len(s.subCredits))
+	return append(append(subs, s.subCast...), s.subCredits...)
+}
+
+// Not specifies that the results must not satisfy all of the filters in the
+// searcher given. For example, New(db).Genre("comedy").Not(New(db).Genre(
+// "animation")) finds comedies that aren't animated. Only filters (like
+// genres, years and ranks) and text are used from the searcher given. Its
+// sort criteria, limit and sub-searches are ignored.
+func (s *Searcher) Not(sub *Searcher) *Searcher {
+	sub.parent = s
+	s.groups = append(s.groups, boolGroup{true, []*Searcher{sub}})
+	return s
+}
+
+// Or specifies that the results must satisfy all of the filters in at least
+// one of the searchers given. This makes it possible to combine different
+// kinds of filters disjunctively, like horror movies or movies with a rank of
+// at least 80. As with Not, only filters and text are used from the searchers
+// given.
+func (s *Searcher) Or(subs ...*Searcher) *Searcher {
+	for _, sub := range subs {
+		sub.parent = s
+	}
+	s.groups = append(s.groups, boolGroup{false, subs})
 	return s
 }
 
@@ -514,6 +919,15 @@ func (s *Searcher) Limit(n int) *Searcher {
 	return s
 }
 
+// Offset specifies the number of results to skip before returning any.
+// It is applied after sorting, so it is only useful for paging through results
+// with a stable sort order. (See also Page, which pages through results
+// without skipping over them in the database.)
+func (s *Searcher) Offset(n int) *Searcher {
+	s.offset = n
+	return s
+}
+
 // Sort specifies the order in which to return the results.
 // Note that Sort can be called multiple times. Each call adds the column and
 // order to the current sort criteria.
@@ -522,6 +936,14 @@ func (s *Searcher) Sort(column, order string) *Searcher {
 	return s
 }
 
+// Macros specifies the macros (or saved searches) that may be used in
+// search queries given to Query. See the documentation for the Macros type
+// for details.
+func (s *Searcher) Macros(ms Macros) *Searcher {
+	s.macros = ms
+	return s
+}
+
 // Chooser specifies the function to call when a sub-search returns 2 or more
 // good hits. See the documentation for the Chooser type for details.
 func (s *Searcher) Chooser(chooser Chooser) *Searcher {
@@ -529,66 +951,15 @@ func (s *Searcher) Chooser(chooser Chooser) *Searcher {
 	return s
 }
 
-// queryTokens breaks a search query into tokens. Namely, a token is whitespace
-// delimited, except when curly braces ('{' and '}') are presents. For example,
-// in the string "a b {x y z} c", there are exactly four tokens: "a", "b",
-// "{x y z}" and "c".
-func queryTokens(query string) []string {
-	var tokens []string
-	var buf []rune
-	curlyDepth := 0
-	for _, r := range query {
-		switch r {
-		case ' ', '\n', '\r', '\t':
-			if curlyDepth == 0 {
-				if len(buf) > 0 {
-					tokens = append(tokens, string(buf))
-				}
-				buf = nil
-			} else {
-				buf = append(buf, r)
-			}
-		case '{':
-			curlyDepth++
-			buf = append(buf, r)
-		case '}':
-			curlyDepth--
-			buf = append(buf, r)
-			if curlyDepth == 0 {
-				tokens = append(tokens, string(buf))
-				buf = nil
-			}
-		default:
-			buf = append(buf, r)
-		}
-	}
-	if len(buf) > 0 {
-		tokens = append(tokens, string(buf))
-	}
-	return tokens
-}
-
-// argOption returns the name and optional value corresponding to a search
-// parameter in a query string. Query params are of the form '{name[:val]}'.
-func argOption(arg string) (name, val string) {
-	if len(arg) < 3 {
-		return
-	}
-	if arg[0] != '{' || arg[len(arg)-1] != '}' {
-		return
-	}
-	arg = arg[1 : len(arg)-1]
-	sep := strings.Index(arg, ":")
-	if sep == -1 {
-		name = arg
-	} else {
-		name, val = arg[0:sep], arg[sep+1:]
-	}
-	name, val = strings.TrimSpace(name), strings.TrimSpace(val)
-	return
-}
-
 func (s *Searcher) sql() string {
+	// The search key of the text being searched is always the first
+	// parameter, since it may be referenced more than once. (Unless it is
+	// matched with the trigram index of names, which doesn't use the key
+	// itself.)
+	s.args = nil
+	if len(s.name) > 0 && !s.trigramIndexed() {
+		s.bind(s.nameKey())
+	}
 	q := sf(`
 		SELECT
 			%s AS entity,
@@ -630,6 +1001,11 @@ func (s *Searcher) sql() string {
 			AS attrs,
 			COALESCE(rating.votes, 0) AS votes,
 			COALESCE(rating.rank, 0) AS rank,
+			COALESCE(rating.distribution, '') AS distribution,
+			%s,
+			%s,
+			%s,
+			%s
 			%s
 		FROM name
 		LEFT JOIN movie AS m ON name.atom_id = m.atom_id
@@ -640,6 +1016,8 @@ func (s *Searcher) sql() string {
 		LEFT JOIN rating ON name.atom_id = rating.atom_id
 		LEFT JOIN mpaa_rating ON name.atom_id = mpaa_rating.atom_id
 		%s
+		%s
+		%s
 		WHERE
 			COALESCE(m.atom_id, t.atom_id, e.atom_id, a.atom_id) IS NOT NULL
 			AND
@@ -647,78 +1025,148 @@ func (s *Searcher) sql() string {
 		%s
 		%s
 		`,
-		s.entityColumn(), s.similarColumn("name.name"), s.creditAttrs(),
-		s.creditJoin(), s.where(), s.orderby(), s.limitClause())
+		s.entityColumn(), s.similarColumn(s.keyColumn()),
+		s.weightedColumn(), s.releasedColumn(), s.snippetColumn(),
+		s.creditAttrs(), s.pageColumns(), s.creditJoin(), s.trigramJoin(),
+		s.releasedJoin(), s.where(), s.orderby(), s.limitClause())
 	if s.debug {
 		pef("%s\n", q)
+		if len(s.args) > 0 {
+			pef("Parameters: %#v\n", s.args)
+		}
 	}
 	return q
 }
 
+// wrappedSQL returns the search query without any sorting or limits, so
+// that it can be used as a subquery.
+func (s *Searcher) wrappedSQL() string {
+	s.wrapped = true
+	defer func() { s.wrapped = false }()
+	return s.sql()
+}
+
+// bind adds a value to the parameters of the SQL query and returns the
+// placeholder that refers to it. Searchers in boolean groups bind their values
+// in the searcher being executed.
+func (s *Searcher) bind(v interface{}) string {
+	if s.parent != nil {
+		return s.parent.bind(v)
+	}
+	s.args = append(s.args, v)
+	return s.placeholder(len(s.args))
+}
+
+// placeholder returns the placeholder of the nth parameter of the SQL query.
+// Values are not always bound in the order that their placeholders appear in
+// the query. SQLite numbers parameters like '$1' in the order that they
+// appear rather than by their number, so '?NNN' is used instead.
+//
+// If the query is part of the query of another search, then its parameters
+// are numbered after the ones bound before it. (See setSQL.)
+func (s *Searcher) placeholder(n int) string {
+	n += s.root().argOffset
+	if s.db.Driver == "sqlite3" {
+		return sf("?%d", n)
+	}
+	return sf("$%d", n)
+}
+
 func (s *Searcher) limitClause() string {
-	if s.limit < 0 {
+	if s.wrapped {
 		return ""
-	} else {
-		return sf("LIMIT %d", s.limit)
 	}
+	var clause string
+	if s.limit >= 0 {
+		clause = sf("LIMIT %d", s.limit)
+	} else if s.offset > 0 && s.db.Driver == "sqlite3" {
+		// SQLite doesn't allow an OFFSET without a LIMIT.
+		clause = "LIMIT -1"
+	}
+	if s.offset > 0 {
+		clause += sf(" OFFSET %d", s.offset)
+	}
+	return clause
 }
 
+// creditJoin returns a join with the credit table for every cast and credits
+// sub-search. (See creditAlias.)
 func (s *Searcher) creditJoin() string {
 	var joins string
-	if !s.subCast.empty() {
+	for i, sub := range s.subCast {
+		if sub.empty() {
+			continue
+		}
 		joins += sf(`
-		LEFT JOIN credit AS c_actor ON
-			name.atom_id = c_actor.media_atom_id
-			AND c_actor.actor_atom_id = %d
-		`, s.subCast.id)
+		LEFT JOIN credit AS %s ON
+			name.atom_id = %s.media_atom_id
+			AND %s.actor_atom_id = %d
+		`, creditAlias("c_actor", i), creditAlias("c_actor", i),
+			creditAlias("c_actor", i), sub.id)
 	}
-	if !s.subCredits.empty() {
+	for i, sub := range s.subCredits {
+		if sub.empty() {
+			continue
+		}
 		joins += sf(`
-		LEFT JOIN credit AS c_media ON
-			a.atom_id = c_media.actor_atom_id
-			AND c_media.media_atom_id = %d
-		`, s.subCredits.id)
+		LEFT JOIN credit AS %s ON
+			a.atom_id = %s.actor_atom_id
+			AND %s.media_atom_id = %d
+		`, creditAlias("c_media", i), creditAlias("c_media", i),
+			creditAlias("c_media", i), sub.id)
 	}
 	return joins
 }
 
+// creditAlias returns the alias of the credit table joined for the i'th cast
+// (with prefix 'c_actor') or credits (with prefix 'c_media') sub-search. The
+// first one has no suffix, so that it can be referred to by sort fields.
+func creditAlias(prefix string, i int) string {
+	if i == 0 {
+		return prefix
+	}
+	return sf("%s%d", prefix, i+1)
+}
+
+// creditAliases returns the aliases of every credit table joined, with cast
+// sub-searches first.
+func (s *Searcher) creditAliases() []string {
+	var aliases []string
+	for i, sub := range s.subCast {
+		if !sub.empty() {
+			aliases = append(aliases, creditAlias("c_actor", i))
+		}
+	}
+	for i, sub := range s.subCredits {
+		if !sub.empty() {
+			aliases = append(aliases, creditAlias("c_media", i))
+		}
+	}
+	return aliases
+}
+
+// creditAttrs returns the credit columns of the search query. Each column is
+// the value of the first credit table joined that has a credit for the
+// result.
 func (s *Searcher) creditAttrs() string {
-	act, med := !s.subCast.empty(), !s.subCredits.empty()
-	switch {
-	case !act && !med:
-		return `
-		0 AS c_actor_id,
-		0 AS c_media_id,
-		'' AS c_character,
-		0 AS c_position,
-		'' AS c_attrs
-		`
-	case !act && med:
-		return `
-		COALESCE(c_media.actor_atom_id, 0) AS c_actor_id,
-		COALESCE(c_media.media_atom_id, 0) AS c_media_id,
-		COALESCE(c_media.character, '') AS c_character,
-		COALESCE(c_media.position, 0) AS c_position,
-		COALESCE(c_media.attrs, '') AS c_attrs
-		`
-	case act && !med:
-		return `
-		COALESCE(c_actor.actor_atom_id, 0) AS c_actor_id,
-		COALESCE(c_actor.media_atom_id, 0) AS c_media_id,
-		COALESCE(c_actor.character, '') AS c_character,
-		COALESCE(c_actor.position, 0) AS c_position,
-		COALESCE(c_actor.attrs, '') AS c_attrs
-		`
-	case act && med:
-		return `
-		COALESCE(c_actor.actor_atom_id, c_media.actor_atom_id) AS c_actor_id,
-		COALESCE(c_actor.media_atom_id, c_media.media_atom_id) AS c_media_id,
-		COALESCE(c_actor.character, c_media.character) AS c_character,
-		COALESCE(c_actor.position, c_media.position) AS c_position,
-		COALESCE(c_actor.attrs, c_media.attrs) AS c_attrs
-		`
-	}
-	panic("unreachable")
+	col := func(name, empty, as string) string {
+		var vals []string
+		for _, alias := range s.creditAliases() {
+			vals = append(vals, sf("%s.%s", alias, name))
+		}
+		if len(vals) == 0 {
+			return sf("%s AS %s", empty, as)
+		}
+		vals = append(vals, empty)
+		return sf("COALESCE(%s) AS %s", strings.Join(vals, ", "), as)
+	}
+	return strings.Join([]string{
+		col("actor_atom_id", "0", "c_actor_id"),
+		col("media_atom_id", "0", "c_media_id"),
+		col("character", "''", "c_character"),
+		col("position", "0", "c_position"),
+		col("attrs", "''", "c_attrs"),
+	}, ",\n")
 }
 
 func (s *Searcher) where() string {
@@ -731,8 +1179,33 @@ func (s *Searcher) where() string {
 
 	conj = append(conj, s.inStrs("mpaa_rating.rating", s.mpaas))
 	conj = append(conj, s.inSubquery("genre", "name", s.genres))
+	if len(s.languages) > 0 {
+		conj = append(conj,
+			s.existsSubquery("language", s.anyCond("lower(name) = %s",
+				s.languages)))
+	}
+	if len(s.soundMixes) > 0 {
+		var patterns []string
+		for _, mix := range s.soundMixes {
+			patterns = append(patterns, "%"+mix+"%")
+		}
+		conj = append(conj,
+			s.existsSubquery("sound_mix", s.anyCond("lower(mix) LIKE %s",
+				patterns)))
+	}
+	if s.color != nil {
+		cond := sf("color = cast(%d as boolean)", boolInt(*s.color))
+		conj = append(conj, s.existsSubquery("color_info", cond))
+	}
+	if s.runtime != nil {
+		conj = append(conj,
+			s.existsSubquery("running_time", s.runtime.cond("minutes")))
+	}
 
-	if !s.subTvshow.empty() {
+	if s.subTvshow != nil && s.subTvshow.set {
+		conj = append(conj,
+			sf("e.tvshow_atom_id IN (%s)", s.subTvshow.setSQL(s)))
+	} else if !s.subTvshow.empty() {
 		conj = append(conj, sf("e.tvshow_atom_id = %d", s.subTvshow.id))
 	}
 	if s.atom > 0 {
@@ -747,6 +1220,9 @@ func (s *Searcher) where() string {
 	if s.votes != nil {
 		conj = append(conj, s.votes.cond("rating.votes"))
 	}
+	if s.polarization != nil {
+		conj = append(conj, s.polarization.cond(polarizationColumn))
+	}
 	if s.season != nil {
 		cond := sf("(e.atom_id IS NULL OR %s)", s.season.cond("e.season"))
 		conj = append(conj, cond)
@@ -755,6 +1231,18 @@ func (s *Searcher) where() string {
 		cond := sf("(e.atom_id IS NULL OR %s)", s.episode.cond("e.episode_num"))
 		conj = append(conj, cond)
 	}
+	if s.aired != nil {
+		cond := sf("e.aired IS NOT NULL AND %s", s.aired.cond(s, "e.aired"))
+		conj = append(conj, cond)
+	}
+	for _, ts := range s.texts {
+		conj = append(conj, sf("name.atom_id IN (%s)", s.textMatches(ts)))
+	}
+	if s.released != nil {
+		cond := sf("rd.released IS NOT NULL AND %s",
+			s.released.cond(s, "rd.released"))
+		conj = append(conj, cond)
+	}
 	if s.noTvMovie {
 		conj = append(conj, "(m.atom_id IS NULL OR m.tv = cast(0 as boolean))")
 	}
@@ -762,20 +1250,51 @@ func (s *Searcher) where() string {
 		conj = append(conj,
 			"(m.atom_id IS NULL OR m.video = cast(0 as boolean))")
 	}
-	if len(s.name) > 0 {
-		if s.fuzzy {
-			conj = append(conj, "name.name % $1")
-		} else {
-			if s.db.Driver == "postgres" {
-				conj = append(conj, sf("name.name ILIKE $1"))
-			} else {
-				conj = append(conj, sf("name.name LIKE $1"))
-			}
+	for _, g := range s.groups {
+		conj = append(conj, g.cond())
+	}
+	if len(s.name) > 0 && s.trigramIndexed() {
+		// The text of the searcher being executed is matched by joining with
+		// the trigram index. (See trigramJoin.)
+		if s.parent != nil {
+			matches := s.trigramMatches(s.nameKey())
+			conj = append(conj, sf(
+				"name.atom_id IN (SELECT atom_id FROM (%s) AS trgm)", matches))
+		}
+	} else if len(s.name) > 0 {
+		// The search key of the searcher being executed is always the first
+		// parameter. Search keys are already in lower case, so LIKE is
+		// always case insensitive.
+		param, col := s.placeholder(1), s.keyColumn()
+		if s.parent != nil {
+			param = s.bind(s.nameKey())
+		}
+		switch {
+		case s.fuzzy:
+			conj = append(conj, sf("%s %% %s", col, param))
+		case strings.ContainsAny(strings.Join(s.name, " "), "%_"):
+			conj = append(conj, sf("%s LIKE %s", col, param))
+		default:
+			conj = append(conj, sf("%s = %s", col, param))
 		}
 	}
 	return strings.Join(conj, " AND ")
 }
 
+// cond returns a SQL condition for a boolean group. A searcher whose filters
+// evaluate to NULL (e.g., a rank range for an unranked result) doesn't match.
+func (g boolGroup) cond() string {
+	var disj []string
+	for _, sub := range g.subs {
+		disj = append(disj,
+			sf("COALESCE((%s), cast(0 as boolean))", sub.where()))
+	}
+	if g.not {
+		return sf("NOT %s", disj[0])
+	}
+	return sf("(%s)", strings.Join(disj, " OR "))
+}
+
 // assumes that the strings in vals are safe for SQL.
 func (s *Searcher) inStrs(col string, vals []string) string {
 	if len(vals) == 0 {
@@ -806,24 +1325,93 @@ func (s *Searcher) inSubquery(table, col string, vals []string) string {
 		)`, strings.Join(unions, " UNION "), col, table)
 }
 
+// existsSubquery returns a condition that is satisfied when the attribute
+// table given has at least one row for the result satisfying cond.
+func (s *Searcher) existsSubquery(table, cond string) string {
+	return sf(`
+		EXISTS (
+			SELECT 1 FROM %s WHERE atom_id = name.atom_id AND (%s)
+		)`, table, cond)
+}
+
+// anyCond returns a disjunction of the condition format given, with each
+// value bound as a parameter in place of the format's '%s'.
+func (s *Searcher) anyCond(format string, vals []string) string {
+	var disj []string
+	for _, v := range vals {
+		disj = append(disj, sf(format, s.bind(v)))
+	}
+	return strings.Join(disj, " OR ")
+}
+
+// nullTime scans a date that may be NULL into a time.Time, which is left
+// alone for NULL. SQLite forgets that dates computed by aggregates are dates,
+// so they may also be scanned as Unix timestamps.
+type nullTime struct {
+	t *time.Time
+}
+
+func (nt nullTime) Scan(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+	case time.Time:
+		*nt.t = v
+	case int64:
+		*nt.t = time.Unix(v, 0).UTC()
+	default:
+		return ef("Could not scan %T as a date.", v)
+	}
+	return nil
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// whereCredits returns the conditions satisfied by results with credits for
+// the cast and credits sub-searches. The billing range applies to every
+// credit.
 func (s *Searcher) whereCredits() []string {
-	var conj []string
-	var joined string
-	if !s.subCredits.empty() {
-		conj = append(conj, sf("c_media.actor_atom_id IS NOT NULL"))
-		joined = "c_media"
+	var conds []string
+	billing := func(col string) string {
+		if s.billing == nil {
+			return ""
+		}
+		return " AND " + s.billing.cond(col)
 	}
-	if !s.subCast.empty() {
-		conj = append(conj, sf("c_actor.media_atom_id IS NOT NULL"))
-		joined = "c_actor"
+	// The column of a joined credit that isn't joined on is only NULL
+	// without a credit.
+	add := func(subs []*subsearch, prefix, col, setCol string) {
+		for i, sub := range subs {
+			switch {
+			case sub.set:
+				conds = append(conds, sf(`
+					EXISTS (
+						SELECT 1 FROM credit AS cs
+						WHERE cs.%s = name.atom_id AND cs.%s IN (%s)%s
+					)`, col, setCol, sub.setSQL(s), billing("cs.position")))
+			case !sub.empty():
+				alias := creditAlias(prefix, i)
+				conds = append(conds, sf("%s.%s IS NOT NULL%s",
+					alias, col, billing(alias+".position")))
+			}
+		}
 	}
-	if len(joined) > 0 && s.billing != nil {
-		conj = append(conj, s.billing.cond(sf("%s.position", joined)))
+	add(s.subCast, "c_actor", "media_atom_id", "actor_atom_id")
+	add(s.subCredits, "c_media", "actor_atom_id", "media_atom_id")
+	if s.anyCredits && len(conds) > 1 {
+		return []string{sf("((%s))", strings.Join(conds, ") OR ("))}
 	}
-	return conj
+	return conds
 }
 
 func (s *Searcher) orderby() string {
+	if s.wrapped {
+		return ""
+	}
 	q, prefix := "", ""
 	for _, ord := range s.order {
 		qualed := orderColumnQualified(ord.column)
@@ -862,14 +1450,241 @@ func (s *Searcher) entityColumn() string {
 			END`
 }
 
+// weighted returns true if and only if the search needs the weighted rank of
+// each result.
+func (s *Searcher) weighted() bool {
+	for _, ord := range s.order {
+		if ord.column == "weighted" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Searcher) weightedColumn() string {
+	if !s.weighted() {
+		return "0 AS weighted"
+	}
+	m, c := s.weightVotes, s.weightMean
+	if m < 0 {
+		m = 0
+	}
+	return sf(`
+			CASE
+				WHEN COALESCE(rating.votes, 0) + %d = 0 THEN 0
+				ELSE (cast(COALESCE(rating.votes, 0) AS real)
+					  * COALESCE(rating.rank, 0) + %d * %f)
+					 / (COALESCE(rating.votes, 0) + %d)
+			END AS weighted`, m, m, c, m)
+}
+
+// releasedByDate returns true if the search (or any of its boolean groups) is
+// restricted or sorted by release date, in which case the earliest release
+// date of each result is computed.
+func (s *Searcher) releasedByDate() bool {
+	if s.released != nil {
+		return true
+	}
+	for _, g := range s.groups {
+		for _, sub := range g.subs {
+			if sub.releasedByDate() {
+				return true
+			}
+		}
+	}
+	for _, ord := range s.order {
+		if ord.column == "released" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Searcher) releasedColumn() string {
+	if !s.releasedByDate() {
+		return "NULL AS released"
+	}
+	return "rd.released AS released"
+}
+
+// releasedJoin joins the earliest release date of each media item,
+// restricted to the release country when one is set. The date is computed
+// once here so that it can be used for restricting, sorting and showing the
+// results.
+func (s *Searcher) releasedJoin() string {
+	if !s.releasedByDate() {
+		return ""
+	}
+	country := ""
+	if len(s.releaseCountry) > 0 {
+		country = sf("WHERE lower(country) = %s", s.bind(s.releaseCountry))
+	}
+	return sf(`
+		LEFT JOIN (
+			SELECT atom_id, MIN(released) AS released
+			FROM release_date
+			%s
+			GROUP BY atom_id
+		) AS rd ON name.atom_id = rd.atom_id
+		`, country)
+}
+
+// textMatches returns a SQL query selecting the atom identifiers of every
+// entity with text matching the full-text search given.
+func (s *Searcher) textMatches(ts textSearch) string {
+	if s.db.Driver == "sqlite3" {
+		fts := imdb.TextSearchTable(ts.table)
+		return sf(`
+			SELECT txt.atom_id
+			FROM %s
+			INNER JOIN %s AS txt ON txt.rowid = %s.rowid
+			WHERE %s MATCH %s`,
+			fts, ts.table, fts, fts, s.bind(ftsQuery(ts.query)))
+	}
+	return sf(`
+			SELECT atom_id FROM %s
+			WHERE %s`, ts.table, s.tsMatch(ts))
+}
+
+// tsMatch returns a PostgreSQL condition that is true when the text in the
+// table of the full-text search matches its query.
+func (s *Searcher) tsMatch(ts textSearch) string {
+	return sf("to_tsvector('%s', %s) @@ plainto_tsquery('%s', %s)",
+		imdb.TextSearchConfig, textColumns[ts.table],
+		imdb.TextSearchConfig, s.bind(ts.query))
+}
+
+// snippetColumn returns a SQL expression that computes an excerpt of the
+// text matched by the first full-text search (if there is one).
+func (s *Searcher) snippetColumn() string {
+	if len(s.texts) == 0 {
+		return "'' AS snippet"
+	}
+	ts := s.texts[0]
+	if s.db.Driver == "sqlite3" {
+		fts := imdb.TextSearchTable(ts.table)
+		return sf(`
+			COALESCE((
+				SELECT snippet(%s, 0, '[', ']', '...', 16)
+				FROM %s
+				INNER JOIN %s AS txt ON txt.rowid = %s.rowid
+				WHERE %s MATCH %s AND txt.atom_id = name.atom_id
+				LIMIT 1
+			), '') AS snippet`,
+			fts, fts, ts.table, fts, fts, s.bind(ftsQuery(ts.query)))
+	}
+	return sf(`
+			COALESCE((
+				SELECT ts_headline('%s', %s, plainto_tsquery('%s', %s),
+					'StartSel=[, StopSel=], MinWords=10, MaxWords=25')
+				FROM %s
+				WHERE atom_id = name.atom_id AND %s
+				LIMIT 1
+			), '') AS snippet`,
+		imdb.TextSearchConfig, textColumns[ts.table],
+		imdb.TextSearchConfig, s.bind(ts.query), ts.table, s.tsMatch(ts))
+}
+
+// ftsQuery converts plain text into an FTS5 query where every word must
+// appear in the matching text. Each word is quoted so that characters in the
+// text are never interpreted as FTS5 query syntax.
+func ftsQuery(text string) string {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		words = append(words, `"`+strings.Replace(word, `"`, `""`, -1)+`"`)
+	}
+	return strings.Join(words, " ")
+}
+
 func (s *Searcher) similarColumn(col string) string {
-	if len(s.name) > 0 && s.fuzzy {
-		return sf("COALESCE(similarity(%s, $1), 0) AS similarity", col)
+	if len(s.name) > 0 && s.trigramIndexed() {
+		return "trgm.similarity AS similarity"
+	} else if len(s.name) > 0 && s.fuzzy {
+		return sf("COALESCE(similarity(%s, %s), 0) AS similarity",
+			col, s.placeholder(1))
 	} else {
 		return "-1 AS similarity"
 	}
 }
 
+// trigramIndexed returns true if fuzzy searching is done with the trigram
+// index of names in SQLite, rather than with PostgreSQL's pg_trgm extension.
+// (See imdb.DB.IndexTrigrams.)
+func (s *Searcher) trigramIndexed() bool {
+	return s.fuzzy && s.db.Driver == "sqlite3"
+}
+
+// trigramJoin returns a join with the names matching the text of the search
+// in the trigram index, if the trigram index is used.
+func (s *Searcher) trigramJoin() string {
+	if len(s.name) == 0 || !s.trigramIndexed() {
+		return ""
+	}
+	return sf(`
+		INNER JOIN (%s) AS trgm ON name.atom_id = trgm.atom_id
+		`, s.trigramMatches(s.nameKey()))
+}
+
+// keyColumn returns the column that the text of the search is matched
+// against. This is the search key of names, unless some names don't have one
+// yet, in which case names in lower case are used instead. (See
+// imdb.DB.HasNameKeys.)
+func (s *Searcher) keyColumn() string {
+	if !s.nameKeys {
+		return "lower(name.name)"
+	}
+	return "name.search_key"
+}
+
+// nameKey returns the search key of the text of the search, which is matched
+// against the search keys of names. (See imdb.NameKey.) Wildcards in the text
+// are preserved, and the text between them is converted to search keys
+// separately. If names don't all have search keys, then the text is only
+// converted to lower case. (See keyColumn.)
+func (s *Searcher) nameKey() string {
+	text := strings.Join(s.name, " ")
+	if !s.nameKeys {
+		return strings.ToLower(text)
+	}
+	var key []string
+	for {
+		i := strings.IndexAny(text, "%_")
+		if i == -1 {
+			break
+		}
+		key = append(key, imdb.NameKey(text[:i]), text[i:i+1])
+		text = text[i+1:]
+	}
+	return strings.Join(append(key, imdb.NameKey(text)), "")
+}
+
+// trigramMatches returns a SQLite query selecting the atom identifier of every
+// name whose search key has trigrams similar to the key given, along with its
+// similarity.
+// The similarity is computed in the same way as pg_trgm and imdb.Similarity:
+// the number of trigrams in common over the number of distinct trigrams in
+// both. Only names with a similarity of at least the threshold are selected.
+func (s *Searcher) trigramMatches(key string) string {
+	tgs := imdb.Trigrams(key)
+	if len(tgs) == 0 {
+		return "SELECT 0 AS atom_id, 0.0 AS similarity WHERE 1 = 0"
+	}
+	var params []string
+	for _, tg := range tgs {
+		params = append(params, s.bind(tg))
+	}
+	similarity := sf("cast(COUNT(*) AS real) / (%d + MAX(total) - COUNT(*))",
+		len(tgs))
+	return sf(`
+			SELECT atom_id, %s AS similarity
+			FROM name_trigram
+			WHERE trigram IN (%s)
+			GROUP BY atom_id
+			HAVING %s >= %s`,
+		similarity, strings.Join(params, ", "), similarity,
+		s.bind(s.root().similarThreshold))
+}
+
 func newIrange(min, max int) *irange {
 	switch {
 	case min < 0 && max < 0:
@@ -896,6 +1711,23 @@ func (ir *irange) cond(col string) string {
 	}
 }
 
+// cond returns a SQL condition restricting col to the range of dates. The
+// dates are bound as parameters of the searcher's query, so that they are
+// compared in whatever representation the database driver uses for dates.
+func (dr *drange) cond(s *Searcher, col string) string {
+	var conj []string
+	if !dr.min.IsZero() {
+		conj = append(conj, sf("%s >= %s", col, s.bind(dr.min)))
+	}
+	if !dr.max.IsZero() {
+		conj = append(conj, sf("%s <= %s", col, s.bind(dr.max)))
+	}
+	if len(conj) == 0 {
+		return "1 = 1"
+	}
+	return strings.Join(conj, " AND ")
+}
+
 // qualifiedColumns maps a user-facing name of a column to the actual column
 // named used in the SQL query.
 var qualifiedColumns = map[string]string{
@@ -908,13 +1740,38 @@ var qualifiedColumns = map[string]string{
 
 	"season":  "e.season",
 	"episode": "e.episode_num",
+	"aired":   "e.aired",
+
+	// The earliest release date is joined by Searcher.releasedJoin.
+	"released": "rd.released",
 
-	"rank":  "rating.rank",
-	"votes": "rating.votes",
+	"rank":         "rating.rank",
+	"votes":        "rating.votes",
+	"polarization": polarizationColumn,
+	"weighted":     "weighted",
 
 	"billing": "c_media.position",
 }
 
+// polarizationColumn is a SQL expression that computes the polarization of
+// a rating from its vote distribution. It mirrors
+// imdb.VoteDistribution.Polarization.
+var polarizationColumn = sf("(%s + %s)",
+	voteShare("rating.distribution", 1), voteShare("rating.distribution", 10))
+
+// voteShare returns a SQL expression that computes the approximate percentage
+// of votes with the given score from a vote distribution column. It mirrors
+// imdb.VoteDistribution.Share.
+func voteShare(col string, score int) string {
+	c := sf("substr(%s, %d, 1)", col, score)
+	return sf(`
+		CASE
+			WHEN %s = '*' THEN 100
+			WHEN %s >= '0' AND %s <= '9' THEN 10 * cast(%s AS integer) + 5
+			ELSE 0
+		END`, c, c, c, c)
+}
+
 func orderColumnQualified(column string) string {
 	return qualifiedColumns[column]
 }
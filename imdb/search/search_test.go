@@ -1,7 +1,15 @@
 package search
 
 import (
+	"io/ioutil"
 	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
 
 	"github.com/BurntSushi/goim/imdb"
 )
@@ -46,3 +54,471 @@ func ExampleQuery() {
 		log.Println(result)
 	}
 }
+
+// TestSQLiteParameters checks that the values of a search are bound to the
+// right parameters with SQLite, which numbers parameters like '$1' in the
+// order that they appear in a query instead of by their number.
+func TestSQLiteParameters(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	tests := []struct {
+		query string
+		ids   []imdb.Atom
+	}{
+		{"the matrix", []imdb.Atom{1, 2}},
+		{"{aired:2000..}", []imdb.Atom{7}},
+		{"%o% {aired:..1999-12-31}", []imdb.Atom{6}},
+		{"homr {aired:2001-01-07}", []imdb.Atom{7}},
+		{"%o% {aired:1990..2010} {sort:aired desc}", []imdb.Atom{6, 7}},
+		{"the matrix {language:english}", []imdb.Atom{1}},
+		{"{lang:german} {lang:FRENCH}", []imdb.Atom{2, 4}},
+		{"%matrix% {sound:dolby} {runtime:130-}", []imdb.Atom{1, 3}},
+		{"%matrix% {released:2000..}", []imdb.Atom{3}},
+		{"{released:..2003-05-10} {rcountry:USA}", []imdb.Atom{1, 4}},
+		{"{rcountry:japan} {released:1999-09-01..}", []imdb.Atom{1}},
+		{"{lang:english} | {lang:german}", []imdb.Atom{1, 3, 4}},
+		{"%matrix% -{lang:english}", []imdb.Atom{2}},
+		{"%matrix% {-lang:english}", []imdb.Atom{2}},
+		{"%matrix% {not:{released:2000..}}", []imdb.Atom{1, 2}},
+		{"(%reloaded% | casablanca) {years:1940-2004}", []imdb.Atom{3, 4}},
+		{"(matrix reloaded, the | casablanca)", []imdb.Atom{3, 4}},
+		// One result for each of Keanu's credits.
+		{"{cast:%keanu%} {cast:%fishburne%}", []imdb.Atom{1, 1, 3}},
+		{"{cast:%keanu%} {cast:%fishburne%} {years:2000-}",
+			[]imdb.Atom{3}},
+		{"%matrix% {cast*:%keanu%} {years:2000-}", []imdb.Atom{3}},
+		{"{credits*:%matrix% {years:1999}}", []imdb.Atom{8, 9}},
+		{"%o% {show*:simpsons}", []imdb.Atom{6, 7}},
+		{"%a% ({runtime:-110} | %simpson% {tvshow}) -{years:1989}",
+			[]imdb.Atom{4}},
+	}
+	for _, test := range tests {
+		s, err := Query(db, test.query)
+		if err != nil {
+			t.Errorf("Could not parse '%s': %s", test.query, err)
+			continue
+		}
+		rs, err := s.Results()
+		if err != nil {
+			t.Errorf("Could not search '%s': %s", test.query, err)
+			continue
+		}
+		var ids []imdb.Atom
+		for _, r := range rs {
+			ids = append(ids, r.Id)
+		}
+		sort.Sort(atoms(ids))
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Expected results %v for '%s' but got %v.",
+				test.ids, test.query, ids)
+		}
+	}
+}
+
+// TestSQLiteSimilar checks that the values of the search that Similar ranks
+// results of are bound to the right parameters with SQLite. (See
+// TestSQLiteParameters.)
+func TestSQLiteSimilar(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	media, err := imdb.FromAtom(db, imdb.EntityMovie, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only The Matrix Reloaded shares genres and cast with The Matrix.
+	tests := []struct {
+		among string
+		ids   []imdb.Atom
+	}{
+		{"{movie}", []imdb.Atom{3}},
+		{"{movie} {language:english}", []imdb.Atom{3}},
+		{"{movie} {language:french}", nil},
+		{"%matrix% {years:2000-2005}", []imdb.Atom{3}},
+		{"%matrix% {-language:english}", nil},
+		{"%reloaded% {-language:german}", []imdb.Atom{3}},
+	}
+	for _, test := range tests {
+		s, err := Query(db, test.among)
+		if err != nil {
+			t.Errorf("Could not parse '%s': %s", test.among, err)
+			continue
+		}
+		rs, err := s.Similar(media)
+		if err != nil {
+			t.Errorf("Could not find media similar among '%s': %s",
+				test.among, err)
+			continue
+		}
+		var ids []imdb.Atom
+		for _, r := range rs {
+			ids = append(ids, r.Id)
+		}
+		sort.Sort(atoms(ids))
+		if !reflect.DeepEqual(ids, test.ids) {
+			t.Errorf("Expected similar media %v among '%s' but got %v.",
+				test.ids, test.among, ids)
+		}
+	}
+}
+
+func TestSQLiteReleased(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	s, err := Query(db, "{released:1900..} {sort:released desc}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := s.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []struct {
+		id       imdb.Atom
+		released string
+	}{
+		{3, "2003-05-07"}, {1, "1999-03-31"}, {4, "1942-11-26"},
+	}
+	if len(rs) != len(exp) {
+		t.Fatalf("Expected %d results but got %d.", len(exp), len(rs))
+	}
+	for i, r := range rs {
+		released := r.Released.Format("2006-01-02")
+		if r.Id != exp[i].id || released != exp[i].released {
+			t.Errorf("Expected result %d to be %d released on %s, but "+
+				"got %d released on %s.", i, exp[i].id, exp[i].released,
+				r.Id, released)
+		}
+	}
+}
+
+func TestSQLiteEach(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	s, err := Query(db, "%matrix% {sort:year asc}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := s.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) < 2 {
+		t.Fatalf("Expected at least 2 results but got %d.", len(rs))
+	}
+
+	var each []Result
+	err = s.Each(func(r Result) error {
+		each = append(each, r)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(each, rs) {
+		t.Errorf("Expected Each to stream\n%v\nbut got\n%v", rs, each)
+	}
+
+	var stopped []Result
+	err = s.Each(func(r Result) error {
+		stopped = append(stopped, r)
+		if len(stopped) == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Expected no error after stopping but got: %s", err)
+	}
+	if !reflect.DeepEqual(stopped, rs[:2]) {
+		t.Errorf("Expected to stop after\n%v\nbut got\n%v", rs[:2], stopped)
+	}
+
+	failed := ef("failed")
+	err = s.Each(func(r Result) error { return failed })
+	if err != failed {
+		t.Errorf("Expected the error of the function but got: %v", err)
+	}
+}
+
+func TestSQLiteFuzzy(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	if db.IsFuzzyEnabled() {
+		t.Fatalf("Fuzzy searching should need an index of trigrams.")
+	}
+	if err := db.IndexTrigrams(); err != nil {
+		t.Fatal(err)
+	}
+	if !db.IsFuzzyEnabled() {
+		t.Fatalf("Fuzzy searching should be enabled by indexing trigrams.")
+	}
+
+	s, err := Query(db, "matrix reloded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := s.Results()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) == 0 || rs[0].Id != 3 {
+		t.Fatalf("Expected 'The Matrix Reloaded' first but got %v.", rs)
+	}
+	key := imdb.NameKey("The Matrix Reloaded")
+	want := imdb.Similarity("matrix reloded", key)
+	if math.Abs(rs[0].Similarity-want) > 1e-6 {
+		t.Errorf("Expected a similarity of %f but got %f.",
+			want, rs[0].Similarity)
+	}
+
+	// Sub-searches in set mode use the similarity threshold of their parent.
+	for _, test := range []struct {
+		query string
+		want  []imdb.Atom
+	}{
+		{"{cast*:fishbern}", nil},
+		{"{similar:0.2} {cast*:fishbern}", []imdb.Atom{1, 3}},
+	} {
+		s, err := Query(db, test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, err := s.Results()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []imdb.Atom
+		for _, r := range rs {
+			got = append(got, r.Id)
+		}
+		sort.Sort(atoms(got))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Expected results %v for '%s' but got %v.",
+				test.want, test.query, got)
+		}
+	}
+}
+
+func TestSQLiteNameKeys(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+
+	ids := func(query string) []imdb.Atom {
+		s, err := Query(db, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs, err := s.Results()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []imdb.Atom
+		for _, r := range rs {
+			ids = append(ids, r.Id)
+		}
+		return ids
+	}
+
+	// Like a database created before search keys existed.
+	if _, err := db.Exec("UPDATE name SET search_key = ''"); err != nil {
+		t.Fatal(err)
+	}
+	var missing int
+	err := db.QueryRow("SELECT COUNT(*) FROM name").Scan(&missing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.HasNameKeys() {
+		t.Fatalf("Names without search keys should be detected.")
+	}
+	// Names in lower case are searched instead.
+	if got := ids("the matrix {sort:year asc}"); !reflect.DeepEqual(
+		got, []imdb.Atom{1, 2}) {
+		t.Errorf("Expected names to be searched without keys but got %v.",
+			got)
+	}
+
+	updated, err := db.UpdateNameKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated != missing {
+		t.Errorf("Expected %d names to be updated but got %d.",
+			missing, updated)
+	}
+	if !db.HasNameKeys() {
+		t.Errorf("Every name should have a search key after updating.")
+	}
+	if got := ids("matrix, the {sort:year asc}"); !reflect.DeepEqual(
+		got, []imdb.Atom{1, 2}) {
+		t.Errorf("Expected search keys to be searched but got %v.", got)
+	}
+	if updated, err := db.UpdateNameKeys(); err != nil || updated != 0 {
+		t.Errorf("Expected no names to be updated again but got %d (%v).",
+			updated, err)
+	}
+}
+
+// testSQLiteDB returns a new SQLite database with a few movies, episodes and
+// credits, along with a function that removes it.
+func testSQLiteDB(t *testing.T) (*imdb.DB, func()) {
+	dir, err := ioutil.TempDir("", "goim-search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := imdb.Open("sqlite3", filepath.Join(dir, "goim.sqlite"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+
+	type stmt struct {
+		q    string
+		args []interface{}
+	}
+	var stmts []stmt
+	add := func(q string, args ...interface{}) {
+		stmts = append(stmts, stmt{q, args})
+	}
+	name := func(id imdb.Atom, name string) {
+		add("INSERT INTO atom (id, hash) VALUES ($1, $2)", id, []byte(name))
+		add("INSERT INTO name (atom_id, name, search_key) VALUES ($1, $2, $3)",
+			id, name, imdb.NameKey(name))
+	}
+
+	movies := []struct {
+		id    imdb.Atom
+		title string
+		year  int
+	}{
+		{1, "The Matrix", 1999},
+		{2, "The Matrix", 2005},
+		{3, "The Matrix Reloaded", 2003},
+		{4, "Casablanca", 1942},
+	}
+	for _, m := range movies {
+		name(m.id, m.title)
+		add("INSERT INTO movie (atom_id, year, sequence, tv, video) "+
+			"VALUES ($1, $2, '', 0, 0)", m.id, m.year)
+	}
+
+	genres := map[imdb.Atom][]string{
+		1: {"action", "sci-fi"},
+		2: {"drama"},
+		3: {"action", "sci-fi"},
+		4: {"drama", "romance"},
+	}
+	for id, names := range genres {
+		for _, g := range names {
+			add("INSERT INTO genre (atom_id, name) VALUES ($1, $2)", id, g)
+		}
+	}
+
+	attrs := []struct {
+		id      imdb.Atom
+		lang    string
+		mix     string
+		minutes int
+	}{
+		{1, "English", "Dolby Digital", 136},
+		{2, "French", "Mono", 120},
+		{3, "English", "Dolby", 138},
+		{4, "German", "Mono", 102},
+	}
+	for _, a := range attrs {
+		add("INSERT INTO language (atom_id, name, attrs) "+
+			"VALUES ($1, $2, '')", a.id, a.lang)
+		add("INSERT INTO sound_mix (atom_id, mix, attrs) "+
+			"VALUES ($1, $2, '')", a.id, a.mix)
+		add("INSERT INTO running_time (atom_id, country, minutes) "+
+			"VALUES ($1, '', $2)", a.id, a.minutes)
+	}
+
+	releases := []struct {
+		id               imdb.Atom
+		country          string
+		year, month, day int
+	}{
+		{1, "USA", 1999, 3, 31},
+		{1, "Japan", 1999, 9, 11},
+		{3, "USA", 2003, 5, 15},
+		{3, "France", 2003, 5, 7},
+		{4, "USA", 1942, 11, 26},
+	}
+	for _, r := range releases {
+		released := time.Date(r.year, time.Month(r.month), r.day,
+			0, 0, 0, 0, time.UTC)
+		add("INSERT INTO release_date (atom_id, country, released, attrs) "+
+			"VALUES ($1, $2, $3, '')", r.id, r.country, released)
+	}
+
+	name(5, "The Simpsons")
+	add("INSERT INTO tvshow " +
+		"(atom_id, year, sequence, year_start, year_end) " +
+		"VALUES (5, 1989, '', 1989, 0)")
+	episodes := []struct {
+		id               imdb.Atom
+		title            string
+		season, episode  int
+		year, month, day int
+	}{
+		{6, "Lisa the Iconoclast", 7, 16, 1996, 2, 18},
+		{7, "HOMR", 12, 9, 2001, 1, 7},
+	}
+	for _, e := range episodes {
+		name(e.id, e.title)
+		aired := time.Date(e.year, time.Month(e.month), e.day,
+			0, 0, 0, 0, time.UTC)
+		add("INSERT INTO episode "+
+			"(atom_id, tvshow_atom_id, year, season, episode_num, aired) "+
+			"VALUES ($1, 5, $2, $3, $4, $5)",
+			e.id, e.year, e.season, e.episode, aired)
+	}
+
+	add("INSERT INTO aka_title (atom_id, title, attrs) VALUES ($1, $2, '')",
+		4, "Everybody Comes to Rick's")
+
+	name(8, "Reeves, Keanu")
+	name(9, "Fishburne, Laurence")
+	add("INSERT INTO actor (atom_id, sequence) VALUES (8, ''), (9, '')")
+	credits := []struct {
+		actor, media imdb.Atom
+		character    string
+		position     int
+	}{
+		{8, 1, "Neo", 1},
+		{8, 1, "Thomas Anderson", 1},
+		{8, 3, "Neo", 1},
+		{9, 1, "Morpheus", 2},
+		{9, 3, "Morpheus", 2},
+	}
+	for _, c := range credits {
+		add("INSERT INTO credit "+
+			"(actor_atom_id, media_atom_id, character, position, attrs) "+
+			"VALUES ($1, $2, $3, $4, '')",
+			c.actor, c.media, c.character, c.position)
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt.q, stmt.args...); err != nil {
+			cleanup()
+			t.Fatal(err)
+		}
+	}
+	return db, cleanup
+}
+
+type atoms []imdb.Atom
+
+func (as atoms) Len() int           { return len(as) }
+func (as atoms) Swap(i, j int)      { as[i], as[j] = as[j], as[i] }
+func (as atoms) Less(i, j int) bool { return as[i] < as[j] }
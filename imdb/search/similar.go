@@ -0,0 +1,261 @@
+package search
+
+import (
+	"strings"
+
+	"github.com/BurntSushi/csql"
+
+	"github.com/BurntSushi/goim/imdb"
+)
+
+// SimilarWeights are the weights of each part of the score computed by
+// Similar. Every part is between 0 and 1 before it is weighted, so the
+// weights give the relative importance of each part.
+type SimilarWeights struct {
+	// Genres and Languages weigh the fraction of the genres and languages
+	// of the media that are shared.
+	Genres, Languages float64
+
+	// Cast weighs the fraction of the top billed cast of the media that are
+	// also top billed.
+	Cast float64
+
+	// Links weighs whether there is a link (e.g., a sequel or a remake)
+	// between the result and the media.
+	Links float64
+
+	// Year weighs how close the year of the result is to the year of the
+	// media. Results released SimilarYears apart or more score 0.
+	Year float64
+
+	// Rank weighs the user rank of the result.
+	Rank float64
+}
+
+// DefaultSimilarWeights are the weights used by Similar when none are given.
+var DefaultSimilarWeights = SimilarWeights{
+	Genres:    3,
+	Languages: 1,
+	Cast:      2,
+	Links:     2,
+	Year:      1,
+	Rank:      1,
+}
+
+// SimilarYears is the number of years between two media at which they are no
+// longer considered close in time.
+const SimilarYears = 20
+
+// SimilarBilling is the lowest billing position of an actor that is
+// considered top billed.
+const SimilarBilling = 5
+
+// SimilarResult is a search result along with how similar it is to media.
+// (See Similar.)
+type SimilarResult struct {
+	Result
+
+	// Score is the weighted sum of each part of the similarity.
+	Score float64
+
+	// Genres, Languages and Cast are the number of genres, languages and
+	// top billed actors shared with the media.
+	Genres, Languages, Cast int
+
+	// Linked is true when there is a link between the result and the media.
+	Linked bool
+}
+
+// Similar returns the results of the search that are most similar to the
+// media given ("more like this"), sorted by their score in descending order.
+// The media itself is never a result. If no weights are given, then
+// DefaultSimilarWeights is used.
+//
+// Only results that share a genre or a top billed actor with the media, or
+// that are linked to it, are scored. Their score is the weighted sum of:
+//
+//	the fraction of the genres of the media that they share,
+//	the fraction of the languages of the media that they share,
+//	the fraction of the top billed cast of the media that they share,
+//	1 if there is a link between them and the media, or 0 otherwise,
+//	how close their year is to the year of the media,
+//	and their user rank divided by 100.
+//
+// All other parameters of the search, like its limit and filters, apply as
+// usual, except that its sort criteria are ignored. For example, to find the
+// 10 movies with at least 1,000 votes that are most similar to media:
+//
+//	s, err := Query(db, "{movie} {votes:1000-} {limit:10}")
+//	rs, err := s.Similar(media)
+func (s *Searcher) Similar(
+	media imdb.Entity,
+	weights ...SimilarWeights,
+) (rs []SimilarResult, err error) {
+	defer csql.Safe(&err)
+
+	if media.Type() == imdb.EntityActor {
+		return nil, ef("Cannot find media similar to an actor: '%s'.", media)
+	}
+	w := DefaultSimilarWeights
+	if len(weights) > 0 {
+		w = weights[0]
+	}
+	var genres imdb.Genres
+	var langs imdb.Languages
+	csql.Panic(media.Attrs(s.db, &genres))
+	csql.Panic(media.Attrs(s.db, &langs))
+	var cast []string
+	rows := csql.Query(s.db, `
+		SELECT DISTINCT actor_atom_id
+		FROM credit
+		WHERE media_atom_id = $1 AND position BETWEEN 1 AND $2
+		`, media.Ident(), SimilarBilling)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var id imdb.Atom
+		csql.Scan(scanner, &id)
+		cast = append(cast, id.String())
+	})
+
+	if err := s.prepare(); err != nil {
+		return nil, err
+	}
+	inner := s.wrappedSQL()
+
+	var genreNames, langNames []string
+	for _, g := range genres {
+		genreNames = append(genreNames, s.bind(g.Name))
+	}
+	seen := make(map[string]bool)
+	for _, lang := range langs {
+		if !seen[lang.Name] {
+			seen[lang.Name] = true
+			langNames = append(langNames, s.bind(lang.Name))
+		}
+	}
+
+	// Each kind of overlap is counted in its own query, and the counts are
+	// summed for every candidate. Languages don't make a candidate on their
+	// own, since far too many media share a language. Links are followed in
+	// both directions, since not every link has an inverse (e.g., "version
+	// of").
+	parts := []string{
+		sf(`
+			SELECT link_atom_id AS atom_id,
+				0 AS genres, 0 AS languages, 0 AS actors, 1 AS links
+			FROM link
+			WHERE atom_id = %s
+			`, media.Ident()),
+		sf(`
+			SELECT atom_id, 0, 0, 0, 1
+			FROM link
+			WHERE link_atom_id = %s
+			`, media.Ident()),
+	}
+	if len(genreNames) > 0 {
+		parts = append(parts, sf(`
+			SELECT atom_id, COUNT(DISTINCT name), 0, 0, 0
+			FROM genre
+			WHERE name IN (%s)
+			GROUP BY atom_id
+			`, strings.Join(genreNames, ", ")))
+	}
+	if len(langNames) > 0 {
+		parts = append(parts, sf(`
+			SELECT atom_id, 0, COUNT(DISTINCT name), 0, 0
+			FROM language
+			WHERE name IN (%s)
+			GROUP BY atom_id
+			`, strings.Join(langNames, ", ")))
+	}
+	if len(cast) > 0 {
+		parts = append(parts, sf(`
+			SELECT media_atom_id, 0, 0, COUNT(DISTINCT actor_atom_id), 0
+			FROM credit
+			WHERE actor_atom_id IN (%s) AND position BETWEEN 1 AND %d
+			GROUP BY media_atom_id
+			`, strings.Join(cast, ", "), SimilarBilling))
+	}
+
+	var cols []string
+	for _, col := range resultColumns {
+		cols = append(cols, "results."+col)
+	}
+	score := sf(`
+		%s * cand.genres
+		+ %s * cand.languages
+		+ %s * cand.actors
+		+ %s * (CASE WHEN cand.links > 0 THEN 1 ELSE 0 END)
+		+ %s * (%s)
+		+ %s * results.rank / 100.0
+		`,
+		perItem(w.Genres, len(genreNames)),
+		perItem(w.Languages, len(langNames)),
+		perItem(w.Cast, len(cast)), sqlFloat(w.Links),
+		sqlFloat(w.Year), yearProximity(media.EntityYear()), sqlFloat(w.Rank))
+	q := sf(`
+		SELECT
+			%s,
+			%s AS score,
+			cand.genres, cand.languages, cand.actors, cand.links
+		FROM (
+			SELECT
+				atom_id,
+				SUM(genres) AS genres, SUM(languages) AS languages,
+				SUM(actors) AS actors, SUM(links) AS links
+			FROM (%s) AS parts
+			GROUP BY atom_id
+			HAVING SUM(genres) + SUM(actors) + SUM(links) > 0
+		) AS cand
+		INNER JOIN (%s) AS results ON results.atom_id = cand.atom_id
+		WHERE results.atom_id <> %s
+		ORDER BY score DESC, results.atom_id ASC
+		%s
+		`,
+		strings.Join(cols, ", "), score,
+		strings.Join(parts, " UNION ALL "), inner, media.Ident(),
+		s.limitClause())
+	if s.debug {
+		pef("%s\n", q)
+	}
+
+	rows = csql.Query(s.db, q, s.args...)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		var r SimilarResult
+		var links int
+		r.Result = scanResult(scanner,
+			&r.Score, &r.Genres, &r.Languages, &r.Cast, &links)
+		r.Linked = links > 0
+		rs = append(rs, r)
+	})
+	return rs, nil
+}
+
+// perItem returns the weight given divided by the number of items, as an
+// SQL number. If there are no items, then it is 0.
+func perItem(weight float64, items int) string {
+	if items == 0 {
+		return "0"
+	}
+	return sqlFloat(weight / float64(items))
+}
+
+// yearProximity returns an expression that is 1 for results released in the
+// year given, and that decreases linearly to 0 for results released
+// SimilarYears apart. It is 0 if either year is unknown.
+func yearProximity(year int) string {
+	if year <= 0 {
+		return "0"
+	}
+	diff := sf("ABS(results.year - %d)", year)
+	return sf(`
+		CASE
+			WHEN results.year > 0 AND %s < %d
+			THEN 1.0 - %s / %d.0
+			ELSE 0
+		END`, diff, SimilarYears, diff, SimilarYears)
+}
+
+// sqlFloat formats a float as an SQL number.
+func sqlFloat(f float64) string {
+	return sf("%f", f)
+}
@@ -0,0 +1,217 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/csql"
+	"github.com/BurntSushi/goim/imdb"
+)
+
+// Suggestion is a relaxed version of a search that has results when the
+// original search doesn't. (See Suggest.)
+type Suggestion struct {
+	// Relaxations describes each way in which the search was relaxed, in
+	// the order that they were applied. e.g., "ignoring years and dates".
+	Relaxations []string
+
+	// Query is the search query string of the relaxed search.
+	Query string
+
+	// Results are the results of the relaxed search.
+	Results []Result
+}
+
+// The similarity thresholds used when relaxing a search. Typos are corrected
+// with a lower threshold than fuzzy searches, since only the most similar
+// name is used.
+const (
+	relaxedThreshold = 0.2
+	typoThreshold    = 0.1
+)
+
+// relaxation relaxes a search in place. It returns a description of what was
+// relaxed, or an empty string if it doesn't apply to the search. Database
+// errors cause a panic that should be recovered with csql.Safe.
+type relaxation func(s *Searcher) string
+
+// relaxations are the ways that a search is relaxed by Suggest, in order.
+var relaxations = []relaxation{
+	relaxThreshold,
+	relaxDates,
+	relaxEntities,
+	relaxAkaTitles,
+	relaxTypos,
+}
+
+// Suggest finds a relaxed version of the search that has results, which is
+// useful for suggesting an alternative ("Did you mean ...?") when the search
+// has none. The search is relaxed one step at a time, and every step is
+// applied on top of the ones before it:
+//
+//	1. The similarity threshold of a fuzzy search is lowered.
+//	2. Filters on years and dates are ignored.
+//	3. Filters on entity types are ignored.
+//	4. The text is replaced by the name of an entity with an alternate
+//	   title (from the aka_title table) matching the text.
+//	5. The text is replaced by the most similar name in the database,
+//	   which corrects typos. This requires fuzzy searching.
+//
+// Steps that don't apply to the search are skipped. The first relaxed search
+// with results is returned. If no relaxed search has results, then a nil
+// suggestion is returned.
+//
+// Filters inside boolean groups and sub-searches are never relaxed, and
+// sub-searches are not resolved again. Steps that change the text are
+// skipped if the text contains wildcards.
+func (s *Searcher) Suggest() (sg *Suggestion, err error) {
+	defer csql.Safe(&err)
+
+	relaxed := New(s.db)
+	if err := relaxed.Query(s.Canonical()); err != nil {
+		return nil, err
+	}
+	relaxed.goodThreshold = s.goodThreshold
+	relaxed.chooser = s.chooser
+	relaxed.subTvshow = s.subTvshow
+	relaxed.subCredits, relaxed.subCast = s.subCredits, s.subCast
+
+	var applied []string
+	for _, relax := range relaxations {
+		desc := relax(relaxed)
+		if len(desc) == 0 {
+			continue
+		}
+		applied = append(applied, desc)
+
+		rs, err := relaxed.Results()
+		if err != nil {
+			return nil, err
+		}
+		if len(rs) > 0 {
+			return &Suggestion{applied, relaxed.Canonical(), rs}, nil
+		}
+	}
+	return nil, nil
+}
+
+func relaxThreshold(s *Searcher) string {
+	if !s.fuzzy || len(s.name) == 0 || s.similarThreshold <= relaxedThreshold {
+		return ""
+	}
+	s.similarThreshold = relaxedThreshold
+	return sf("lowering the similarity threshold to %s",
+		strconv.FormatFloat(relaxedThreshold, 'g', -1, 64))
+}
+
+func relaxDates(s *Searcher) string {
+	if s.year == nil && s.released == nil && s.aired == nil {
+		return ""
+	}
+	s.year, s.released, s.aired = nil, nil, nil
+	return "ignoring years and dates"
+}
+
+func relaxEntities(s *Searcher) string {
+	if len(s.entities) == 0 {
+		return ""
+	}
+	s.entities = nil
+	return "ignoring entity types"
+}
+
+func relaxAkaTitles(s *Searcher) string {
+	if !s.relaxableText() {
+		return ""
+	}
+	text := strings.Join(s.name, " ")
+
+	// With pg_trgm, the most similar alternate title is used. Otherwise,
+	// the shortest alternate title containing every word of the text is.
+	var q string
+	var args []interface{}
+	if s.fuzzy && s.db.Driver == "postgres" {
+		q = `
+			SELECT name.name
+			FROM aka_title AS aka
+			INNER JOIN name ON name.atom_id = aka.atom_id
+			WHERE aka.title % $1
+			ORDER BY similarity(aka.title, $1) DESC
+			LIMIT 1
+			`
+		args = []interface{}{text}
+	} else {
+		words := strings.Fields(imdb.NameKey(text))
+		q = `
+			SELECT name.name
+			FROM aka_title AS aka
+			INNER JOIN name ON name.atom_id = aka.atom_id
+			WHERE lower(aka.title) LIKE $1
+			ORDER BY length(aka.title) ASC
+			LIMIT 1
+			`
+		args = []interface{}{"%" + strings.Join(words, "%") + "%"}
+	}
+	return s.replaceText(q, args, "searching alternate titles for '%s'")
+}
+
+func relaxTypos(s *Searcher) string {
+	if !s.fuzzy || !s.relaxableText() {
+		return ""
+	}
+	key := s.nameKey()
+
+	var q string
+	var args []interface{}
+	if s.trigramIndexed() {
+		matches := New(s.db).SimilarThreshold(typoThreshold)
+		q = sf(`
+			SELECT name.name
+			FROM (%s) AS trgm
+			INNER JOIN name ON name.atom_id = trgm.atom_id
+			ORDER BY trgm.similarity DESC
+			LIMIT 1
+			`, matches.trigramMatches(key))
+		args = matches.args
+	} else {
+		csql.Exec(s.db, "SELECT set_limit($1)", typoThreshold)
+		q = sf(`
+			SELECT name.name
+			FROM name
+			WHERE %s %% $1
+			ORDER BY similarity(%s, $1) DESC
+			LIMIT 1
+			`, s.keyColumn(), s.keyColumn())
+		args = []interface{}{key}
+	}
+	return s.replaceText(q, args, "correcting '%s'")
+}
+
+// relaxableText returns true if the text of the search can be replaced by
+// a relaxation.
+func (s *Searcher) relaxableText() bool {
+	text := strings.Join(s.name, " ")
+	return len(strings.TrimSpace(text)) > 0 && !strings.ContainsAny(text, "%_")
+}
+
+// replaceText replaces the text of the search with the name selected by the
+// query given, if it selects a name with a different search key. The
+// description given is formatted with the original text.
+func (s *Searcher) replaceText(
+	q string,
+	args []interface{},
+	desc string,
+) string {
+	var name string
+	rows := csql.Query(s.db, q, args...)
+	csql.ForRow(rows, func(scanner csql.RowScanner) {
+		csql.Scan(scanner, &name)
+	})
+	if len(name) == 0 || imdb.NameKey(name) == s.nameKey() {
+		return ""
+	}
+	text := strings.Join(s.name, " ")
+	s.name = nil
+	s.Text(name)
+	return sf(desc, text)
+}
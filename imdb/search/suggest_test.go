@@ -0,0 +1,77 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSQLiteSuggest(t *testing.T) {
+	db, cleanup := testSQLiteDB(t)
+	defer cleanup()
+	if err := db.IndexTrigrams(); err != nil {
+		t.Fatal(err)
+	}
+
+	lowered := "lowering the similarity threshold to 0.2"
+	tests := []struct {
+		query       string
+		relaxations []string
+		query2      string
+		first       int
+	}{
+		{"matrx relodd", []string{lowered}, "matrx relodd {similar:0.2}", 3},
+		{"matrx relodd {years:1990}",
+			[]string{lowered, "ignoring years and dates"},
+			"matrx relodd {similar:0.2}", 3},
+		{"matrx relodd {tvshow} {years:1990}",
+			[]string{
+				lowered, "ignoring years and dates", "ignoring entity types",
+			},
+			"matrx relodd {similar:0.2}", 3},
+		{"everybody comes",
+			[]string{
+				lowered, "searching alternate titles for 'everybody comes'",
+			},
+			"Casablanca {similar:0.2}", 4},
+		{"kasabalanka {tvshow}",
+			[]string{
+				lowered, "ignoring entity types", "correcting 'kasabalanka'",
+			},
+			"Casablanca {similar:0.2}", 4},
+		{"%zzz%", nil, "", 0},
+	}
+	for _, test := range tests {
+		s, err := Query(db, test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sg, err := s.Suggest()
+		if err != nil {
+			t.Errorf("Could not suggest for '%s': %s", test.query, err)
+			continue
+		}
+		if test.relaxations == nil {
+			if sg != nil {
+				t.Errorf("Expected no suggestion for '%s' but got %#v.",
+					test.query, sg)
+			}
+			continue
+		}
+		if sg == nil {
+			t.Errorf("Expected a suggestion for '%s'.", test.query)
+			continue
+		}
+		if !reflect.DeepEqual(sg.Relaxations, test.relaxations) {
+			t.Errorf("Expected '%s' to be relaxed by\n%q\nbut got\n%q",
+				test.query, test.relaxations, sg.Relaxations)
+		}
+		if sg.Query != test.query2 {
+			t.Errorf("Expected '%s' to be relaxed to '%s' but got '%s'.",
+				test.query, test.query2, sg.Query)
+		}
+		if len(sg.Results) == 0 || int(sg.Results[0].Id) != test.first {
+			t.Errorf("Expected %d first in the suggestion for '%s' "+
+				"but got %v.", test.first, test.query, sg.Results)
+		}
+	}
+}
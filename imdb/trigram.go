@@ -0,0 +1,124 @@
+package imdb
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/csql"
+)
+
+// Trigrams returns the set of trigrams in the text given, which are used for
+// fuzzy searching. They are the same as the trigrams of PostgreSQL's pg_trgm
+// extension: the text is converted to lower case and split into words of
+// letters and digits, and each word is padded with two spaces at the
+// beginning and one space at the end.
+func Trigrams(text string) []string {
+	var tgs []string
+	seen := make(map[string]bool)
+	notWord := func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), notWord) {
+		rs := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(rs); i++ {
+			tg := string(rs[i : i+3])
+			if !seen[tg] {
+				seen[tg] = true
+				tgs = append(tgs, tg)
+			}
+		}
+	}
+	return tgs
+}
+
+// Similarity returns the similarity of two strings as a number between 0 and
+// 1, where 1 means that they have the same trigrams. This is the same as the
+// similarity function of PostgreSQL's pg_trgm extension.
+func Similarity(a, b string) float64 {
+	tgsa, tgsb := Trigrams(a), Trigrams(b)
+	if len(tgsa) == 0 || len(tgsb) == 0 {
+		return 0
+	}
+	inb := make(map[string]bool, len(tgsb))
+	for _, tg := range tgsb {
+		inb[tg] = true
+	}
+	common := 0
+	for _, tg := range tgsa {
+		if inb[tg] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(tgsa)+len(tgsb)-common)
+}
+
+// IndexTrigrams rebuilds the index of the trigrams in the search key of every
+// name (see NameKey), which is used for fuzzy searching with SQLite. It should
+// be called whenever names are added or changed. (This is done automatically
+// by 'goim load'.)
+//
+// This does nothing with PostgreSQL, which uses the pg_trgm extension for
+// fuzzy searching instead.
+func (db *DB) IndexTrigrams() (err error) {
+	defer csql.Safe(&err)
+
+	if db.Driver != "sqlite3" {
+		return nil
+	}
+	csql.Exec(db, "DROP INDEX IF EXISTS idx_name_trigram_trigram")
+
+	tx, err := db.Begin()
+	csql.Panic(err)
+	csql.Truncate(tx, db.Driver, "name_trigram")
+	ins, err := csql.NewInserter(tx, db.Driver, "name_trigram",
+		"atom_id", "trigram", "total")
+	csql.Panic(err)
+
+	// Names are read in batches so that they aren't all kept in memory and
+	// so that rows aren't being read while trigrams are inserted.
+	const batch = 10000
+	type name struct {
+		id  Atom
+		key string
+	}
+	last := Atom(0)
+	for {
+		var names []name
+		rows := csql.Query(tx, `
+			SELECT atom_id, search_key FROM name
+			WHERE atom_id > $1
+			ORDER BY atom_id ASC
+			LIMIT $2
+			`, last, batch)
+		csql.ForRow(rows, func(scanner csql.RowScanner) {
+			var n name
+			csql.Scan(scanner, &n.id, &n.key)
+			names = append(names, n)
+		})
+		if len(names) == 0 {
+			break
+		}
+		for _, n := range names {
+			tgs := Trigrams(n.key)
+			for _, tg := range tgs {
+				csql.Panic(ins.Exec(n.id, tg, len(tgs)))
+			}
+		}
+		last = names[len(names)-1].id
+	}
+	csql.Panic(ins.Exec()) // inserts anything left in the buffer
+	csql.Panic(tx.Commit())
+
+	// The index includes every column, so that similarities can be computed
+	// from the index alone.
+	csql.Exec(db, `
+		CREATE INDEX idx_name_trigram_trigram
+		ON name_trigram (trigram, atom_id, total)
+		`)
+
+	// Whether fuzzy searching is enabled depends on the index.
+	db.mu.Lock()
+	db.fuzzy = nil
+	db.mu.Unlock()
+	return
+}
@@ -301,6 +301,8 @@ func parseMediaEntity(entity []byte) (imdb.Entity, bool) {
 var attrPrefixes = [][]byte{
 	[]byte("aka"), []byte("version of"),
 	[]byte("follows"), []byte("followed by"),
+	[]byte("remake of"), []byte("remade as"),
+	[]byte("spin off from"), []byte("spin off"),
 	[]byte("alternate language version of"),
 }
 
@@ -33,13 +33,13 @@ func listActors(db *imdb.DB, ractor, ractress io.ReadCloser) (err error) {
 	csql.Truncate(txcredit.Tx, db.Driver, "credit")
 
 	actIns, err := csql.NewInserter(txactor.Tx, db.Driver, "actor",
-		"atom_id", "sequence")
+		"atom_id", "sequence", "gender")
 	csql.Panic(err)
 	credIns, err := csql.NewInserter(txcredit.Tx, db.Driver, "credit",
 		"actor_atom_id", "media_atom_id", "character", "position", "attrs")
 	csql.Panic(err)
 	nameIns, err := csql.NewInserter(txname.Tx, db.Driver, "name",
-		"atom_id", "name")
+		"atom_id", "name", "search_key")
 	csql.Panic(err)
 	atoms, err := newAtomizer(db, txatom.Tx)
 	csql.Panic(err)
@@ -48,8 +48,10 @@ func listActors(db *imdb.DB, ractor, ractress io.ReadCloser) (err error) {
 	// multiple locations. (Or there are different actors that erroneously
 	// have the same name.)
 	added := make(map[imdb.Atom]struct{}, 3000000)
-	n1, nc1 := listActs(db, ractress, atoms, added, actIns, credIns, nameIns)
-	n2, nc2 := listActs(db, ractor, atoms, added, actIns, credIns, nameIns)
+	n1, nc1 := listActs(db, ractress, "female",
+		atoms, added, actIns, credIns, nameIns)
+	n2, nc2 := listActs(db, ractor, "male",
+		atoms, added, actIns, credIns, nameIns)
 
 	csql.Panic(actIns.Exec())
 	csql.Panic(credIns.Exec())
@@ -76,6 +78,7 @@ type credit struct {
 func listActs(
 	db *imdb.DB,
 	r io.ReadCloser,
+	gender string,
 	atoms *atomizer,
 	added map[imdb.Atom]struct{},
 	actIns, credIns, nameIns *csql.Inserter,
@@ -91,7 +94,7 @@ func listActs(
 			return
 		}
 
-		var a imdb.Actor
+		a := imdb.Actor{Gender: gender}
 		existed, err := parseId(atoms, idstr, &a.Id)
 		if err != nil {
 			csql.Panic(err)
@@ -103,7 +106,8 @@ func listActs(
 			}
 
 			// We only add a name when we've added an atom.
-			if err := nameIns.Exec(a.Id, a.FullName); err != nil {
+			key := imdb.NameKey(a.FullName)
+			if err := nameIns.Exec(a.Id, a.FullName, key); err != nil {
 				csql.Panic(ef("Could not add actor name '%s' from '%s': %s",
 					idstr, line, err))
 			}
@@ -117,7 +121,7 @@ func listActs(
 					return
 				}
 			}
-			if err := actIns.Exec(a.Id, a.Sequence); err != nil {
+			if err := actIns.Exec(a.Id, a.Sequence, a.Gender); err != nil {
 				csql.Panic(ef("Could not add actor info '%#v' from '%s': %s",
 					a, line, err))
 			}
@@ -261,7 +261,8 @@ func listRunningTimes(
 
 func listRatings(db *imdb.DB, atoms *atomizer, r io.ReadCloser) (err error) {
 	defer csql.Safe(&err)
-	table := startSimpleLoad(db, "rating", "atom_id", "votes", "rank")
+	table := startSimpleLoad(db, "rating",
+		"atom_id", "votes", "rank", "distribution")
 	defer table.done()
 
 	done := false
@@ -271,6 +272,7 @@ func listRatings(db *imdb.DB, atoms *atomizer, r io.ReadCloser) (err error) {
 			ok    bool
 			votes int
 			rank  float64
+			dist  imdb.VoteDistribution
 		)
 		if done {
 			return
@@ -301,7 +303,12 @@ func listRatings(db *imdb.DB, atoms *atomizer, r io.ReadCloser) (err error) {
 			logf("Could not parse float '%s' in: '%s'", fields[2], line)
 			return
 		}
-		table.add(line, id, votes, int(10*rank))
+		if dist = imdb.VoteDistribution(fields[0]); !dist.Valid() {
+			logf("Could not parse vote distribution '%s' in: '%s'",
+				fields[0], line)
+			dist = ""
+		}
+		table.add(line, id, votes, int(10*rank), string(dist))
 	})
 	return
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"io"
 	"strconv"
+	"time"
 
 	"github.com/BurntSushi/csql"
 	"github.com/BurntSushi/goim/imdb"
@@ -49,10 +50,10 @@ func listMovies(db *imdb.DB, movies io.ReadCloser) (err error) {
 		"atom_id", "year", "sequence", "year_start", "year_end")
 	csql.Panic(err)
 	epIns, err := csql.NewInserter(txepisode.Tx, db.Driver, "episode",
-		"atom_id", "tvshow_atom_id", "year", "season", "episode_num")
+		"atom_id", "tvshow_atom_id", "year", "season", "episode_num", "aired")
 	csql.Panic(err)
 	nameIns, err := csql.NewInserter(txname.Tx, db.Driver, "name",
-		"atom_id", "name")
+		"atom_id", "name", "search_key")
 	csql.Panic(err)
 	atoms, err := newAtomizer(db, txatom.Tx)
 	csql.Panic(err)
@@ -91,7 +92,8 @@ func listMovies(db *imdb.DB, movies io.ReadCloser) (err error) {
 				csql.Panic(err)
 			} else if !existed {
 				// We only add a name when we add an atom.
-				if err = nameIns.Exec(m.Id, m.Title); err != nil {
+				key := imdb.NameKey(m.Title)
+				if err = nameIns.Exec(m.Id, m.Title, key); err != nil {
 					logf("Full movie info (that failed to add): %#v", m)
 					csql.Panic(ef("Could not add name '%s': %s", m, err))
 				}
@@ -114,7 +116,8 @@ func listMovies(db *imdb.DB, movies io.ReadCloser) (err error) {
 				csql.Panic(err)
 			} else if !existed {
 				// We only add a name when we add an atom.
-				if err = nameIns.Exec(tv.Id, tv.Title); err != nil {
+				key := imdb.NameKey(tv.Title)
+				if err = nameIns.Exec(tv.Id, tv.Title, key); err != nil {
 					logf("Full tvshow info (that failed to add): %#v", tv)
 					csql.Panic(ef("Could not add name '%s': %s", tv, err))
 				}
@@ -138,13 +141,18 @@ func listMovies(db *imdb.DB, movies io.ReadCloser) (err error) {
 				csql.Panic(err)
 			} else if !existed {
 				// We only add a name when we add an atom.
-				if err = nameIns.Exec(ep.Id, ep.Title); err != nil {
+				key := imdb.NameKey(ep.Title)
+				if err = nameIns.Exec(ep.Id, ep.Title, key); err != nil {
 					logf("Full episode info (that failed to add): %#v", ep)
 					csql.Panic(ef("Could not add name '%s': %s", ep, err))
 				}
 			}
+			var aired interface{}
+			if !ep.AirDate.IsZero() {
+				aired = ep.AirDate
+			}
 			err := epIns.Exec(ep.Id, ep.TvshowId, ep.Year,
-				ep.Season, ep.EpisodeNum)
+				ep.Season, ep.EpisodeNum, aired)
 			if err != nil {
 				logf("Full episode info (that failed to add): %#v", ep)
 				csql.Panic(ef("Could not add episode '%s': %s", ep, err))
@@ -212,13 +220,39 @@ func parseEpisode(az *atomizer, episode []byte, ep *imdb.Episode) bool {
 		}
 	}
 
-	// The season/episode numbers are optional.
+	// The season/episode numbers are optional. Episodes without them (like
+	// those of daily shows) are sometimes identified by their air date.
 	inBraces := episode[openBrace+1 : len(episode)-1]
 	start := parseEpisodeNumbers(inBraces, &ep.Season, &ep.EpisodeNum)
+	if start == len(inBraces) {
+		// An episode identified only by its date keeps it as its title.
+		if start = parseEpisodeDate(inBraces, &ep.AirDate); start == 0 {
+			start = len(inBraces)
+		}
+	}
 	ep.Title = unicode(bytes.TrimSpace(inBraces[0:start]))
 	return true
 }
 
+// parseEpisodeDate looks for an air date of the form '(YYYY-MM-DD)' at the
+// end of an episode identifier. If one is found, it is stored in aired and the
+// index of its opening parenthesis is returned. Otherwise, the length of the
+// identifier is returned.
+func parseEpisodeDate(inBraces []byte, aired *time.Time) int {
+	const layout = "2006-01-02"
+	start := len(inBraces) - len(layout) - 2
+	if start < 0 || inBraces[start] != '(' || inBraces[len(inBraces)-1] != ')' {
+		return len(inBraces)
+	}
+	date := inBraces[start+1 : len(inBraces)-1]
+	t, err := time.Parse(layout, string(date))
+	if err != nil {
+		return len(inBraces)
+	}
+	*aired = t.UTC()
+	return start
+}
+
 func parseEpisodeNumbers(inBraces []byte, season *int, episode *int) int {
 	if inBraces[len(inBraces)-1] != ')' {
 		return len(inBraces)
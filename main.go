@@ -12,13 +12,23 @@ import (
 )
 
 var commands = []*command{
+	cmdCareer,
+	cmdChart,
+	cmdCostars,
+	cmdFranchise,
 	cmdFull,
+	cmdGraph,
 	cmdShort,
 	cmdLoad,
+	cmdPath,
 	cmdSearch,
+	cmdSimilar,
 	cmdSize,
+	cmdTop,
+	cmdTogether,
 	cmdWrite,
 	cmdRename,
+	cmdSaved,
 	cmdFtp,
 }
 
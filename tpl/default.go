@@ -61,6 +61,9 @@ successive new lines (LF) are replaced with two new lines (LF).
 	{{ if .E.Attrs }}
 		{{ printf " %s" .E.Attrs }}
 	{{ end }}
+	{{ if not .E.Released.IsZero }}
+		{{ printf " (released: %s)" (.E.Released.Format "2006-01-02") }}
+	{{ end }}
 	{{ if not .E.Rank.Unranked }}
 		{{ printf " (rank: %d/100, votes: %d)" .E.Rank.Rank .E.Rank.Votes }}
 	{{ end }}
@@ -72,6 +75,21 @@ successive new lines (LF) are replaced with two new lines (LF).
 			{{ printf " <%d>" .E.Credit.Position }}
 		{{ end }}
 	{{ end }}
+	{{ if .E.Snippet }}
+		{{ printf "\n%14s%s" "" .E.Snippet }}
+	{{ end }}
+
+{{ end }}
+
+{{ define "top_result" }}
+	{{ printf "%3d. %5.1f  %s" .A.Index .E.Weighted .E.Name }}
+	{{ if and (gt .E.Year 0) (ne .E.Entity.String "tvshow") }}
+		{{ printf " (%d)" .E.Year }}
+	{{ end }}
+	{{ if .E.Attrs }}
+		{{ printf " %s" .E.Attrs }}
+	{{ end }}
+	{{ printf " (rank: %d/100, votes: %d)" .E.Rank.Rank .E.Rank.Votes }}
 
 {{ end }}
 
@@ -157,6 +175,10 @@ successive new lines (LF) are replaced with two new lines (LF).
 	{{ if and (gt .E.Season 0) (gt .E.EpisodeNum 0) }}
 		{{ printf "Season %d, Episode %d" .E.Season .E.EpisodeNum }}
 
+	{{ end }}
+	{{ if not .E.AirDate.IsZero }}
+		{{ printf "Aired: %s" (.E.AirDate.Format "2006-01-02") }}
+
 	{{ end }}
 
 	{{ template "short_media_details" .E }}
@@ -515,9 +537,71 @@ successive new lines (LF) are replaced with two new lines (LF).
 		{{ $rank }}
 
 
+		{{ if $rank.Distribution.Valid }}
+			{{ printf "Polarization: %d%%" $rank.Distribution.Polarization }}
+
+
+			{{ $rank.Distribution | histogram 50 }}
+
+
+		{{ end }}
 	{{ end }}
 {{ end }}
 
+{{ define "chart" }}
+
+	{{ printf "Episode ranks for %s" .E | underlined "=" }}
+
+	{{ range $season := .A.Seasons }}
+		{{ template "chart_season" $season }}
+	{{ end }}
+
+	{{ if gt (len .A.Best) 0 }}
+		{{ "Best episodes" | underlined "-" }}
+
+		{{ range $ep := .A.Best }}
+			{{ template "chart_episode" $ep }}
+		{{ end }}
+
+		{{ "Worst episodes" | underlined "-" }}
+
+		{{ range $ep := .A.Worst }}
+			{{ template "chart_episode" $ep }}
+		{{ end }}
+
+	{{ end }}
+{{ end }}
+
+{{ define "chart_season" }}
+	{{ printf "Season %d: %s" .Season (sparkline .Lo .Hi .Ranks) }}
+	{{ if gt .Votes 0 }}
+		{{ printf " (mean rank: %0.1f, votes: %d)" .Mean .Votes }}
+	{{ end }}
+
+	{{ range $ep := .Episodes }}
+		{{ if $ep.Rank.Unranked }}
+			{{ "   -" }}
+		{{ else }}
+			{{ printf " %3d" $ep.Rank.Rank }}
+		{{ end }}
+		{{ if $ep.Best }}
+			{{ "*" }}
+		{{ else if $ep.Worst }}
+			{{ "!" }}
+		{{ else }}
+			{{ " " }}
+		{{ end }}
+	{{ end }}
+
+
+{{ end }}
+
+{{ define "chart_episode" }}
+	{{ printf "S%02dE%02d %s" .Season .EpisodeNum .Title }}
+	{{ printf " (rank: %d/100, votes: %d)" .Rank.Rank .Rank.Votes }}
+
+{{ end }}
+
 {{ define "credits" }}
 
 	{{ printf "Credits for %s" .E | underlined "=" }}
@@ -543,4 +627,191 @@ successive new lines (LF) are replaced with two new lines (LF).
 
 	{{ end }}
 {{ end }}
+
+{{ define "path" }}
+
+	{{ printf "Path from %s to %s" .E .A.To | underlined "=" }}
+
+	{{ printf "Degrees of separation: %d" (len .A.Hops) }}
+
+
+	{{ range $hop := .A.Hops }}
+		{{ if eq "actor" $hop.From.Type.String }}
+			{{ printf "%s %s" $hop.From $hop.FromCredit }}
+			{{ printf " and %s %s" $hop.To $hop.ToCredit }}
+			{{ printf " in %s" $hop.Via }}
+		{{ else }}
+			{{ printf "%s in %s %s" $hop.Via $hop.From $hop.FromCredit }}
+			{{ printf " and in %s %s" $hop.To $hop.ToCredit }}
+		{{ end }}
+
+	{{ end }}
+{{ end }}
+
+{{ define "together" }}
+
+	{{ printf "Credits shared by %s and %s" .E .A.To | underlined "=" }}
+
+	{{ if not (len .A.Hops) }}
+		None found.
+
+	{{ else }}
+		{{ range $hop := .A.Hops }}
+			{{ printf "%s %s %s" $hop.Via $hop.FromCredit $hop.ToCredit }}
+
+		{{ end }}
+
+	{{ end }}
+{{ end }}
+
+{{ define "costars" }}
+
+	{{ printf "Co-stars of %s" .E | underlined "=" }}
+
+	{{ if not (len .A.Costars) }}
+		None found.
+
+	{{ else }}
+		{{ range $c := .A.Costars }}
+			{{ printf "%4d  %s" $c.Shared $c.Actor }}
+			{{ if gt $c.Billing 0 }}
+				{{ printf " (best billing: %d," $c.Billing }}
+				{{ printf " mean: %0.1f)" $c.MeanBilling }}
+			{{ end }}
+
+		{{ end }}
+
+	{{ end }}
+{{ end }}
+
+{{ define "similar" }}
+
+	{{ printf "Media similar to %s" .E | underlined "=" }}
+
+	{{ if not (len .A.Results) }}
+		None found.
+
+	{{ else }}
+		{{ range $r := .A.Results }}
+			{{ printf "%5.2f  %-8s %s" $r.Score $r.Entity $r.Name }}
+			{{ if gt $r.Year 0 }}
+				{{ printf " (%d)" $r.Year }}
+			{{ end }}
+			{{ printf " [genres: %d," $r.Genres }}
+			{{ printf " languages: %d, cast: %d" $r.Languages $r.Cast }}
+			{{ if $r.Linked }}
+				{{ ", linked" }}
+			{{ end }}
+			{{ "]" }}
+
+		{{ end }}
+
+	{{ end }}
+{{ end }}
+
+{{ define "franchise" }}
+
+	{{ printf "Franchise of %s" .E | underlined "=" }}
+
+	{{ if not (len .A.Links) }}
+		None found.
+
+	{{ else }}
+		{{ "Order of release" | underlined "-" }}
+
+		{{ range $e := .A.Media }}
+			{{ $e }}
+
+		{{ end }}
+
+		{{ "Order of story" | underlined "-" }}
+
+		{{ if .A.Chronological }}
+			{{ range $e := .A.Chronological }}
+				{{ $e }}
+
+			{{ end }}
+		{{ else }}
+			Could not be determined.
+
+		{{ end }}
+
+		{{ "Links" | underlined "-" }}
+
+		{{ range $lk := .A.Links }}
+			{{ $lk }}
+
+		{{ end }}
+
+	{{ end }}
+{{ end }}
+
+{{ define "career" }}
+
+	{{ printf "Career of %s" .E | underlined "=" }}
+
+	{{ $career := career .E }}
+	{{ if not $career.Credits }}
+		No credits found.
+
+	{{ else }}
+		{{ printf "Credits: %d" $career.Credits }}
+		{{ printf " (in %d movies and TV shows)" $career.Titles }}
+
+		{{ if $career.First.Valid }}
+			{{ printf "First credit: %s %s" $career.First.Media $career.First }}
+
+			{{ printf "Last credit: %s %s" $career.Last.Media $career.Last }}
+
+		{{ end }}
+		{{ if gt $career.Ranked 0 }}
+			{{ printf "Mean rank: %0.1f/100" $career.MeanRank }}
+			{{ printf " (%d ranked titles)" $career.Ranked }}
+
+		{{ end }}
+		{{ printf "Lead roles: %d," $career.Lead }}
+		{{ printf " supporting roles: %d," $career.Supporting }}
+		{{ printf " unbilled: %d" $career.Unbilled }}
+
+
+		{{ if $career.Years }}
+			{{ "Credits per year" | underlined "-" }}
+
+			{{ range $y := $career.Years }}
+				{{ $bar := bar 50 $y.Titles $career.Peak }}
+				{{ printf "%d %4d %s" $y.Year $y.Titles $bar }}
+
+			{{ end }}
+
+		{{ end }}
+		{{ if $career.Genres }}
+			{{ "Most common genres" | underlined "-" }}
+
+			{{ range $i, $g := $career.Genres }}
+				{{ if lt $i $.A.N }}
+					{{ printf "%4d  %s" $g.Titles $g.Name }}
+
+				{{ end }}
+			{{ end }}
+
+		{{ end }}
+		{{ if $career.TvRoles }}
+			{{ "Longest TV roles" | underlined "-" }}
+
+			{{ range $i, $r := $career.TvRoles }}
+				{{ if lt $i $.A.N }}
+					{{ printf "%4d  %s" $r.Episodes $r.Tvshow }}
+					{{ if $r.Character }}
+						{{ printf " [%s]" $r.Character }}
+					{{ end }}
+					{{ if gt $r.First 0 }}
+						{{ printf " (%d-%d)" $r.First $r.Last }}
+					{{ end }}
+
+				{{ end }}
+			{{ end }}
+
+		{{ end }}
+	{{ end }}
+{{ end }}
 `)
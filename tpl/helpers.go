@@ -33,6 +33,19 @@ var (
 // followed by the string to repeat N times, where N is the length of the
 // string to underline.
 //
+// The "histogram" function takes a maximum bar width and a vote distribution
+// (from imdb.UserRank) and returns an ASCII histogram with one line for each
+// score from 1 to 10.
+//
+// The "sparkline" function takes a lower bound, an upper bound and a list of
+// integers, and returns a single line of ASCII characters where each
+// character represents the magnitude of one integer relative to the bounds.
+// Negative integers are drawn as a space.
+//
+// The "bar" function takes a maximum bar width, an integer and the largest
+// integer to draw, and returns a bar of '#' characters whose width is
+// proportional to the integer. Any positive integer gets at least one '#'.
+//
 // The "count_seasons" function takes one parameter that is a TV show and
 // returns the number of seasons that have aired.
 //
@@ -44,11 +57,16 @@ var (
 //
 // The list of functions starting with "running_times" retrieve attribute
 // values given an entity. All functions accept one argument that must satisfy
-// the imdb.Entity interface and return a list of attribute values.
+// the imdb.Entity interface and return a list of attribute values. (Except
+// for "rank" and "career", which return a single value. "career" only accepts
+// actors.)
 var Functions = template.FuncMap{
 	"lines":      lines,
 	"wrap":       wrap,
 	"underlined": underlined,
+	"histogram":  histogram,
+	"sparkline":  sparkline,
+	"bar":        bar,
 
 	"count_seasons":  countSeasons,
 	"count_episodes": countEpisodes,
@@ -73,6 +91,7 @@ var Functions = template.FuncMap{
 	"quotes":             attrGetter(new(imdb.Quotes)),
 	"rank":               attrGetter(new(imdb.UserRank)),
 	"credits":            attrGetter(new(imdb.Credits)),
+	"career":             attrGetter(new(imdb.Career)),
 
 	"eq": func(a, b interface{}) bool { return a == b },
 	"ne": func(a, b interface{}) bool { return a != b },
@@ -146,6 +165,52 @@ func underlined(rep string, is interface{}) string {
 	return sf("%s\n%s", s, strings.Repeat(rep, len(s)))
 }
 
+func histogram(width int, d imdb.VoteDistribution) string {
+	var lines []string
+	for score := 1; score <= 10; score++ {
+		share := d.Share(score)
+		bar := strings.Repeat("#", (share*width+50)/100)
+		lines = append(lines, sf("%2d | %-*s %3d%%", score, width, bar, share))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sparkLevels are the characters used to draw a sparkline, from lowest to
+// highest.
+const sparkLevels = "_.-:=+*#%@"
+
+func sparkline(lo, hi int, values []int) string {
+	line := make([]byte, len(values))
+	for i, v := range values {
+		switch {
+		case v < 0:
+			line[i] = ' '
+		case hi <= lo:
+			line[i] = sparkLevels[len(sparkLevels)-1]
+		default:
+			level := (v - lo) * (len(sparkLevels) - 1) / (hi - lo)
+			if level < 0 {
+				level = 0
+			} else if level >= len(sparkLevels) {
+				level = len(sparkLevels) - 1
+			}
+			line[i] = sparkLevels[level]
+		}
+	}
+	return string(line)
+}
+
+func bar(width, n, max int) string {
+	if n <= 0 || max <= 0 {
+		return ""
+	}
+	w := n * width / max
+	if w == 0 {
+		w = 1
+	}
+	return strings.Repeat("#", w)
+}
+
 func sorted(xs sort.Interface) interface{} {
 	sort.Sort(xs)
 	return xs